@@ -1,18 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"go-crud-api/internal/config"
 	"go-crud-api/internal/handlers"
+	"go-crud-api/internal/mail"
 	"go-crud-api/internal/middleware"
+	"go-crud-api/internal/models"
 	"go-crud-api/internal/repository"
 	"go-crud-api/internal/services"
+	"go-crud-api/internal/tracing"
 	"go-crud-api/internal/utils"
 )
 
@@ -23,6 +33,12 @@ func main() {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
+	// Трассировка запросов, экспортер задается TRACING_EXPORTER
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to configure tracing: %v", err)
+	}
+
 	// Применение миграций
 	if err := utils.RunMigrations(); err != nil {
 		log.Fatalf("Error running migrations: %v", err)
@@ -34,50 +50,294 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Запросы к пользователям и заказам ограничиваются организацией
+	if err := db.Use(repository.TenantPlugin{}); err != nil {
+		log.Fatalf("Failed to register tenant plugin: %v", err)
+	}
+
+	// Время запросов к БД и статистика пула соединений для /metrics
+	if err := db.Use(repository.MetricsPlugin{DBName: cfg.DB.DBName}); err != nil {
+		log.Fatalf("Failed to register metrics plugin: %v", err)
+	}
+
+	// Span для каждого запроса к БД
+	if err := db.Use(repository.TracingPlugin{}); err != nil {
+		log.Fatalf("Failed to register tracing plugin: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database connection pool: %v", err)
+	}
+
 	// Инициализация зависимостей
 	userRepo := repository.NewUserRepository(db)
 	orderRepo := repository.NewOrderRepository(db)
-	userService := services.NewUserService(userRepo)
-	orderService := services.NewOrderService(orderRepo, userRepo)
-	authHandler := handlers.NewAuthHandler(userService)
-	userHandler := handlers.NewUserHandler(userService)
+	tokenRepo := repository.NewTokenRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	emailVerificationRepo := repository.NewEmailVerificationRepository(db)
+	// 2FA и OIDC работают с пользователем, уже найденным по токену или внешней учетной записи
+	twoFactorRepo := repository.NewTwoFactorRepository(repository.WithoutTenant(db))
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	oidcRepo := repository.NewOIDCRepository(repository.WithoutTenant(db))
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(db)
+	impersonationRepo := repository.NewImpersonationRepository(db)
+	organizationRepo := repository.NewOrganizationRepository(db)
+
+	mailer, err := mail.NewSender(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to configure mail sender: %v", err)
+	}
+
+	passwordService, err := services.NewPasswordService(passwordHistoryRepo, cfg.Password)
+	if err != nil {
+		log.Fatalf("Failed to configure password policy: %v", err)
+	}
+
+	cursorCodec := utils.NewCursorCodec(cfg.Pagination.CursorSecret)
+	// Пользователи и заказы доступны через ForOrg; сервисы входа, токенов и учетной записи
+	// находят пользователя по email, токену или ключу и работают на уровне системы
+	systemUserRepo := userRepo.System()
+	userService := services.NewUserService(userRepo, organizationRepo, loginAttemptRepo, tokenRepo, passwordService, cursorCodec, cfg.Auth)
+	orderService := services.NewOrderService(orderRepo, userRepo, productRepo, cursorCodec, cfg.Auth)
+	productService := services.NewProductService(productRepo)
+	idempotencyService := services.NewIdempotencyService(idempotencyRepo, cfg.Idempotency.TTL)
+	jwtManager, err := utils.NewJWTManager(cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to configure JWT keys: %v", err)
+	}
+	authService := services.NewAuthService(tokenRepo, systemUserRepo, impersonationRepo.System(), jwtManager, cfg.JWT)
+	passwordResetService := services.NewPasswordResetService(systemUserRepo, passwordResetRepo, tokenRepo, apiKeyRepo, passwordService, mailer, cfg.Auth)
+	emailVerificationService := services.NewEmailVerificationService(systemUserRepo, emailVerificationRepo, mailer, cfg.Auth)
+	totpSecrets, err := utils.NewSecretCipher(cfg.Auth.TOTPEncryptionKey)
+	if err != nil {
+		log.Fatalf("Failed to configure TOTP encryption: %v", err)
+	}
+	twoFactorService := services.NewTwoFactorService(systemUserRepo, twoFactorRepo, totpSecrets, cfg.Auth)
+	// Секреты TOTP, сохраненные до появления шифрования, шифруются при запуске
+	if count, err := twoFactorService.WithContext(context.Background()).EncryptPlaintextSecrets(); err != nil {
+		log.Fatalf("Failed to encrypt TOTP secrets: %v", err)
+	} else if count > 0 {
+		log.Printf("Encrypted %d stored TOTP secrets", count)
+	}
+	oidcService := services.NewOIDCService(systemUserRepo, oidcRepo, passwordService, cfg.OIDC)
+	authHandler := handlers.NewAuthHandler(userService, authService, passwordResetService, emailVerificationService, twoFactorService, oidcService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, systemUserRepo)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	sessionHandler := handlers.NewSessionHandler(authService)
+	impersonationService := services.NewImpersonationService(impersonationRepo, userRepo, jwtManager, cfg.Auth)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService)
+	organizationService := services.NewOrganizationService(organizationRepo)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService)
+	userHandler := handlers.NewUserHandler(userService, emailVerificationService)
 	orderHandler := handlers.NewOrderHandler(orderService)
+	productHandler := handlers.NewProductHandler(productService)
+	healthService := services.NewHealthService(sqlDB)
+	healthHandler := handlers.NewHealthHandler(healthService)
 
 	// Настройка маршрутизатора
 	router := gin.Default()
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName), middleware.Tracing())
+	router.Use(middleware.Metrics())
+
+	// IP клиента используется для ограничения попыток входа, поэтому X-Forwarded-For
+	// принимаем только от явно указанных прокси
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// Проверки состояния для оркестратора
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
 
 	// Public routes
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
 	router.POST("/auth/login", authHandler.Login)
+	router.POST("/auth/login/2fa", authHandler.LoginTwoFactor)
+	router.GET("/auth/oidc/login", authHandler.OIDCLogin)
+	router.GET("/auth/oidc/callback", authHandler.OIDCCallback)
 	router.POST("/auth/register", authHandler.Register)
+	router.POST("/auth/refresh", authHandler.Refresh)
+	router.POST("/auth/password/forgot", authHandler.ForgotPassword)
+	router.POST("/auth/password/reset", authHandler.ResetPassword)
+	router.POST("/auth/verify", authHandler.VerifyEmail)
+	router.POST("/auth/verify/resend", authHandler.ResendVerification)
+	router.GET("/products", productHandler.ListProducts)
+	router.GET("/products/:id", productHandler.GetProduct)
 
 	// Protected routes
 	protected := router.Group("/")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(
+		middleware.AuthMiddleware(authService, apiKeyService),
+		middleware.AuditImpersonation(impersonationService),
+		middleware.Idempotency(idempotencyService),
+	)
 	{
-		// User routes
-		users := protected.Group("/users")
+		// Управление учетной записью доступно только с access-токеном, не с ключом API
+		account := protected.Group("/auth", middleware.RequireTokenAuth())
 		{
-			users.GET("", userHandler.GetUsers)
-			users.POST("", userHandler.CreateUser)
-			users.GET("/:id", userHandler.GetUser)
-			users.PUT("/:id", userHandler.UpdateUser)
-			users.DELETE("/:id", userHandler.DeleteUser)
-
-			// Order routes
-			orders := users.Group("/:id/orders")
+			account.POST("/logout", authHandler.Logout)
+			account.POST("/token/exchange", middleware.DenyImpersonation(), authHandler.ExchangeToken)
+
+			// Two-factor routes (для текущего пользователя)
+			twoFactor := account.Group("/2fa", middleware.RequireScopes(models.ScopeAccountWrite), middleware.DenyImpersonation())
 			{
-				orders.GET("", orderHandler.GetUserOrders)
-				orders.POST("", orderHandler.CreateOrder)
-				orders.GET("/:order_id", orderHandler.GetOrder)
-				orders.PUT("/:order_id", orderHandler.UpdateOrder)
-				orders.DELETE("/:order_id", orderHandler.DeleteOrder)
+				twoFactor.POST("/enroll", twoFactorHandler.Enroll)
+				twoFactor.POST("/confirm", twoFactorHandler.Confirm)
+				twoFactor.POST("/disable", twoFactorHandler.Disable)
+				twoFactor.POST("/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
 			}
 		}
+
+		// Admin routes
+		// Вход от имени пользователя и журнал таких входов; из-под чужого имени недоступны
+		admin := protected.Group("/admin", middleware.RequireTokenAuth(), middleware.RequireMethodScope(models.ScopeAdminRead, models.ScopeAdminWrite), middleware.DenyImpersonation())
+		{
+			admin.POST("/impersonate/:id", middleware.RequireRole(models.RoleAdmin, models.RoleSupport), impersonationHandler.Impersonate)
+			admin.GET("/impersonations", middleware.RequireRole(models.RoleAdmin), impersonationHandler.ListImpersonations)
+			admin.GET("/impersonations/:sid", middleware.RequireRole(models.RoleAdmin), impersonationHandler.GetImpersonation)
+
+			// Организации создает и просматривает только администратор платформы
+			admin.GET("/organizations", middleware.RequireRole(models.RoleSuperAdmin), organizationHandler.ListOrganizations)
+			admin.POST("/organizations", middleware.RequireRole(models.RoleSuperAdmin), organizationHandler.CreateOrganization)
+		}
+
+		// Product routes (управление каталогом)
+		products := protected.Group("/products", middleware.RequireMethodScope(models.ScopeProductsRead, models.ScopeProductsWrite))
+		{
+			products.GET("/low-stock", middleware.RequireRole(models.RoleAdmin, models.RoleSupport), productHandler.LowStockReport)
+			products.POST("", middleware.RequireRole(models.RoleAdmin), productHandler.CreateProduct)
+			products.PUT("/:id", middleware.RequireRole(models.RoleAdmin), productHandler.UpdateProduct)
+			products.DELETE("/:id", middleware.RequireRole(models.RoleAdmin), productHandler.DeleteProduct)
+		}
+
+		// Admin order routes
+		protected.GET("/orders", middleware.RequireScopes(models.ScopeOrdersRead), middleware.RequireRole(models.RoleAdmin), orderHandler.ListOrders)
+
+		// User routes
+		// Обычный пользователь работает только со своими данными,
+		// поддержка может просматривать всех, администратор - управлять всеми
+		readSelf := middleware.RequireSelfOrRole("id", models.RoleAdmin, models.RoleSupport)
+		writeSelf := middleware.RequireSelfOrRole("id", models.RoleAdmin)
+		staff := middleware.RequireRole(models.RoleAdmin, models.RoleSupport)
+		tenantUser := middleware.RequireTenantUser("id", userService)
+
+		users := protected.Group("/users", middleware.RequireMethodScope(models.ScopeUsersRead, models.ScopeUsersWrite))
+		{
+			users.GET("", middleware.RequireRole(models.RoleAdmin, models.RoleSupport), userHandler.GetUsers)
+			users.POST("", middleware.RequireRole(models.RoleAdmin), userHandler.CreateUser)
+			users.GET("/:id", readSelf, userHandler.GetUser)
+			users.PUT("/:id", writeSelf, userHandler.UpdateUser)
+			users.DELETE("/:id", writeSelf, middleware.DenyImpersonation(), userHandler.DeleteUser)
+			users.PUT("/:id/role", middleware.RequireRole(models.RoleAdmin), userHandler.UpdateRole)
+			users.POST("/:id/unlock", middleware.RequireRole(models.RoleAdmin), userHandler.UnlockUser)
+		}
+
+		// API key routes
+		// Ключи API выпускаются и отзываются только с access-токеном и только владельцем:
+		// запросы с ключом не попадают в журнал входов от имени пользователя
+		owner := middleware.RequireSelfOrRole("id")
+		apiKeys := protected.Group("/users/:id/api-keys", middleware.RequireTokenAuth(), middleware.RequireMethodScope(models.ScopeAccountRead, models.ScopeAccountWrite), tenantUser)
+		{
+			apiKeys.GET("", readSelf, apiKeyHandler.ListAPIKeys)
+			apiKeys.POST("", owner, middleware.DenyImpersonation(), apiKeyHandler.CreateAPIKey)
+			apiKeys.DELETE("/:key_id", owner, middleware.DenyImpersonation(), apiKeyHandler.RevokeAPIKey)
+		}
+
+		// Session routes (устройства, с которых выполнен вход)
+		sessions := protected.Group("/users/:id/sessions", middleware.RequireTokenAuth(), middleware.RequireMethodScope(models.ScopeAccountRead, models.ScopeAccountWrite), tenantUser)
+		{
+			sessions.GET("", readSelf, sessionHandler.ListSessions)
+			sessions.DELETE("", writeSelf, middleware.DenyImpersonation(), sessionHandler.RevokeOtherSessions)
+			sessions.DELETE("/:sid", writeSelf, middleware.DenyImpersonation(), sessionHandler.RevokeSession)
+		}
+
+		// Order routes
+		orders := protected.Group("/users/:id/orders", middleware.RequireMethodScope(models.ScopeOrdersRead, models.ScopeOrdersWrite))
+		{
+			orders.GET("", readSelf, orderHandler.GetUserOrders)
+			orders.POST("", writeSelf, orderHandler.CreateOrder)
+			orders.GET("/:order_id", readSelf, orderHandler.GetOrder)
+			orders.PUT("/:order_id", writeSelf, orderHandler.UpdateOrder)
+			orders.DELETE("/:order_id", writeSelf, orderHandler.DeleteOrder)
+
+			// Смена статуса заказа
+			orders.GET("/:order_id/history", readSelf, orderHandler.GetOrderHistory)
+			// Оплату подтверждает сотрудник, пользователь не может отметить заказ оплаченным сам
+			orders.POST("/:order_id/pay", staff, orderHandler.PayOrder)
+			orders.POST("/:order_id/cancel", writeSelf, orderHandler.CancelOrder)
+			orders.POST("/:order_id/ship", staff, orderHandler.ShipOrder)
+			orders.POST("/:order_id/deliver", staff, orderHandler.DeliverOrder)
+			orders.POST("/:order_id/refund", staff, orderHandler.RefundOrder)
+		}
 	}
 
 	// Запуск сервера
-	port := fmt.Sprintf(":%d", cfg.Server.Port)
-	if err := router.Run(port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	// Метрики отдаются на отдельном внутреннем порту: на публичном /metrics раскрывал бы
+	// маршруты, объемы заказов и нагрузку любому клиенту
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Server.MetricsPort),
+		Handler:           metricsMux,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 2)
+	go func() {
+		log.Printf("Server is listening on %s", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+	go func() {
+		log.Printf("Metrics are served on %s/metrics", metricsServer.Addr)
+		serverErr <- metricsServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	case <-ctx.Done():
+		stop()
+		log.Printf("Shutting down, waiting up to %s for in-flight requests", cfg.Server.ShutdownTimeout)
+	}
+
+	// Новые запросы не принимаются, текущие дорабатывают до SERVER_SHUTDOWN_TIMEOUT
+	healthService.MarkShuttingDown()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown did not complete: %v", err)
+	}
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Metrics server shutdown did not complete: %v", err)
+	}
+
+	// Соединения с БД закрываются только после завершения запросов
+	if err := sqlDB.Close(); err != nil {
+		log.Printf("Failed to close database connections: %v", err)
+	}
+
+	// Отправка накопленных spans
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
 	}
+	log.Printf("Server stopped")
 }
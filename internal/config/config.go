@@ -1,19 +1,30 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Config содержит все настройки приложения
 // Загружается из переменных окружения
 type Config struct {
-	DB     DBConfig
-	Server ServerConfig
-	JWT    JWTConfig
+	DB          DBConfig
+	Server      ServerConfig
+	JWT         JWTConfig
+	Idempotency IdempotencyConfig
+	Pagination  PaginationConfig
+	Auth        AuthConfig
+	Mail        MailConfig
+	OIDC        OIDCConfig
+	Password    PasswordConfig
+	Tracing     TracingConfig
 }
 
 // DBConfig содержит настройки подключения к базе данных
@@ -28,12 +39,120 @@ type DBConfig struct {
 
 // ServerConfig содержит настройки HTTP сервера
 type ServerConfig struct {
-	Port int
+	Port              int
+	MetricsPort       int           // Внутренний порт /metrics, не публикуется наружу
+	TrustedProxies    []string      // Прокси, которым доверяем X-Forwarded-For при определении IP клиента
+	ReadTimeout       time.Duration // Максимальное время чтения запроса вместе с телом
+	ReadHeaderTimeout time.Duration // Максимальное время чтения заголовков запроса
+	WriteTimeout      time.Duration // Максимальное время от окончания чтения заголовков до записи ответа
+	IdleTimeout       time.Duration // Сколько держать открытым keep-alive соединение без запросов
+	ShutdownTimeout   time.Duration // Сколько ждать завершения текущих запросов при остановке
 }
 
 // JWTConfig содержит настройки JWT токенов
 type JWTConfig struct {
-	Secret string
+	Secret     string
+	AccessTTL  time.Duration // Время жизни access-токена
+	RefreshTTL time.Duration // Время жизни refresh-токена
+
+	Algorithm      string            // HS256, RS256 или EdDSA
+	KeyID          string            // kid текущего ключа подписи
+	PrivateKeyFile string            // PEM файл закрытого ключа для RS256 и EdDSA
+	VerifyKeyFiles map[string]string // kid -> PEM файл открытого ключа, которые еще принимаются после ротации
+	Issuer         string            // Значение iss в выпускаемых токенах
+	Audience       string            // Значение aud в выпускаемых токенах
+}
+
+// IdempotencyConfig содержит настройки обработки заголовка Idempotency-Key
+type IdempotencyConfig struct {
+	TTL time.Duration // Сколько хранится сохраненный ответ
+}
+
+// PaginationConfig содержит настройки пагинации
+type PaginationConfig struct {
+	CursorSecret string // Ключ подписи курсоров keyset-пагинации
+}
+
+// AuthConfig содержит настройки сценариев аутентификации
+type AuthConfig struct {
+	PublicURL        string        // Адрес приложения для ссылок в письмах
+	PasswordResetTTL time.Duration // Время жизни токена сброса пароля
+
+	EmailVerificationTTL  time.Duration // Время жизни ссылки подтверждения email
+	AllowUnverifiedLogin  bool          // Можно ли входить с неподтвержденным email
+	AllowUnverifiedOrders bool          // Можно ли создавать заказы с неподтвержденным email
+
+	TwoFactorIssuer       string        // Название сервиса в приложении-аутентификаторе
+	TwoFactorChallengeTTL time.Duration // Время жизни промежуточного токена входа с 2FA
+	TOTPEncryptionKey     []byte        // Ключ AES-256 для шифрования секретов TOTP в БД
+
+	LoginFailureWindow   time.Duration // За какой период учитываются неудачные попытки входа
+	LoginDelayAfter      int           // После скольких неудач подряд включается нарастающая задержка
+	LoginMaxFailures     int           // После скольких неудач подряд учетная запись блокируется
+	LoginLockoutDuration time.Duration // На сколько блокируется учетная запись
+	LoginIPMaxFailures   int           // Сколько неудачных попыток допускается с одного IP за период
+
+	ImpersonationTTL time.Duration // Время жизни токена входа от имени пользователя
+}
+
+// OIDCConfig содержит настройки входа через внешнего провайдера OpenID Connect
+// Вход через OIDC включен, если задан IssuerURL
+type OIDCConfig struct {
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string        // Адрес /auth/oidc/callback, зарегистрированный у провайдера
+	Scopes        []string      // Запрашиваемые scopes, openid добавляется всегда
+	AutoProvision bool          // Создавать пользователя при первом входе
+	StateTTL      time.Duration // Сколько ждем возврата пользователя от провайдера
+}
+
+// Enabled проверяет, настроен ли вход через OIDC
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != ""
+}
+
+// PasswordConfig содержит политику паролей и параметры хеширования
+type PasswordConfig struct {
+	Algorithm     string // bcrypt или argon2id
+	BcryptCost    int
+	Argon2Time    uint32 // Количество проходов argon2id
+	Argon2Memory  uint32 // Память argon2id в KiB
+	Argon2Threads uint8
+
+	MinLength        int
+	MaxLength        int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	BreachedListFile string // Файл с утекшими паролями, по одному на строку
+	HistorySize      int    // Сколько последних паролей нельзя использовать повторно
+}
+
+// MailConfig содержит настройки отправки писем
+type MailConfig struct {
+	Driver       string // log или smtp
+	From         string
+	LogFile      string // Файл для писем при MAIL_DRIVER=log
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+}
+
+// TracingConfig содержит настройки трассировки OpenTelemetry
+// Адрес OTLP коллектора и заголовки задаются стандартными переменными OTEL_EXPORTER_OTLP_*
+type TracingConfig struct {
+	Exporter    string  // none, otlp, stdout или file
+	File        string  // Файл для span при TRACING_EXPORTER=file
+	ServiceName string  // Имя сервиса в span
+	SampleRatio float64 // Доля трассируемых запросов без входящего traceparent, от 0 до 1
+}
+
+// Enabled сообщает, что трассировка включена
+func (c TracingConfig) Enabled() bool {
+	return c.Exporter != "none"
 }
 
 // Load загружает конфигурацию из .env файла
@@ -65,22 +184,376 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("error loading SERVER_PORT: %v", err)
 	}
 
+	readTimeout, err := getEnvAsDuration("SERVER_READ_TIMEOUT", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	readHeaderTimeout, err := getEnvAsDuration("SERVER_READ_HEADER_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	writeTimeout, err := getEnvAsDuration("SERVER_WRITE_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	idleTimeout, err := getEnvAsDuration("SERVER_IDLE_TIMEOUT", 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTimeout, err := getEnvAsDuration("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// /metrics отдается на отдельном порту, чтобы метрики не были доступны с публичного адреса API
+	metricsPort, err := getEnvAsIntDefault("METRICS_PORT", 9090)
+	if err != nil {
+		return nil, err
+	}
+	if metricsPort < 1 || metricsPort > 65535 || metricsPort == serverPort {
+		return nil, fmt.Errorf("METRICS_PORT must be a valid port different from SERVER_PORT")
+	}
+
 	serverConfig := ServerConfig{
-		Port: serverPort,
+		Port:              serverPort,
+		MetricsPort:       metricsPort,
+		TrustedProxies:    getEnvAsList("TRUSTED_PROXIES"),
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		ShutdownTimeout:   shutdownTimeout,
 	}
 
 	// Загружаем настройки JWT
+	accessTTL, err := getEnvAsDuration("JWT_ACCESS_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTTL, err := getEnvAsDuration("JWT_REFRESH_TTL", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
 	jwtConfig := JWTConfig{
-		Secret: getEnv("JWT_SECRET"),
+		Secret:         os.Getenv("JWT_SECRET"),
+		AccessTTL:      accessTTL,
+		RefreshTTL:     refreshTTL,
+		Algorithm:      getEnvDefault("JWT_ALGORITHM", "HS256"),
+		KeyID:          getEnvDefault("JWT_KEY_ID", "default"),
+		PrivateKeyFile: os.Getenv("JWT_PRIVATE_KEY_FILE"),
+		VerifyKeyFiles: map[string]string{},
+		Issuer:         getEnvDefault("JWT_ISSUER", "go-crud-api"),
+		Audience:       getEnvDefault("JWT_AUDIENCE", "go-crud-api"),
+	}
+
+	switch jwtConfig.Algorithm {
+	case "HS256":
+		if jwtConfig.Secret == "" {
+			return nil, fmt.Errorf("environment variable JWT_SECRET is required for JWT_ALGORITHM=HS256")
+		}
+	case "RS256", "EdDSA":
+		if jwtConfig.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("environment variable JWT_PRIVATE_KEY_FILE is required for JWT_ALGORITHM=%s", jwtConfig.Algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM: %s", jwtConfig.Algorithm)
+	}
+
+	// JWT_VERIFY_KEYS задается как kid=path через запятую
+	for _, item := range getEnvAsList("JWT_VERIFY_KEYS") {
+		kid, path, ok := strings.Cut(item, "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("environment variable JWT_VERIFY_KEYS has invalid item: %s", item)
+		}
+		jwtConfig.VerifyKeyFiles[strings.TrimSpace(kid)] = strings.TrimSpace(path)
+	}
+
+	// Загружаем настройки идемпотентности
+	idempotencyTTL, err := getEnvAsDuration("IDEMPOTENCY_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Загружаем настройки аутентификации
+	passwordResetTTL, err := getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	emailVerificationTTL, err := getEnvAsDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	allowUnverifiedLogin, err := getEnvAsBool("ALLOW_UNVERIFIED_LOGIN", true)
+	if err != nil {
+		return nil, err
+	}
+
+	allowUnverifiedOrders, err := getEnvAsBool("ALLOW_UNVERIFIED_ORDERS", true)
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactorChallengeTTL, err := getEnvAsDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// Секреты TOTP хранятся в БД зашифрованными: утечка дампа не должна раскрывать второй фактор
+	totpEncryptionKey, err := base64.StdEncoding.DecodeString(os.Getenv("TOTP_ENCRYPTION_KEY"))
+	if err != nil || len(totpEncryptionKey) != 32 {
+		return nil, fmt.Errorf("environment variable TOTP_ENCRYPTION_KEY must be 32 bytes encoded in base64")
+	}
+
+	loginFailureWindow, err := getEnvAsDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	loginDelayAfter, err := getEnvAsIntDefault("LOGIN_DELAY_AFTER", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	loginMaxFailures, err := getEnvAsIntDefault("LOGIN_MAX_FAILURES", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	loginLockoutDuration, err := getEnvAsDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	loginIPMaxFailures, err := getEnvAsIntDefault("LOGIN_IP_MAX_FAILURES", 50)
+	if err != nil {
+		return nil, err
+	}
+
+	impersonationTTL, err := getEnvAsDuration("IMPERSONATION_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	authConfig := AuthConfig{
+		PublicURL:             getEnvDefault("APP_PUBLIC_URL", fmt.Sprintf("http://localhost:%d", serverPort)),
+		PasswordResetTTL:      passwordResetTTL,
+		EmailVerificationTTL:  emailVerificationTTL,
+		AllowUnverifiedLogin:  allowUnverifiedLogin,
+		AllowUnverifiedOrders: allowUnverifiedOrders,
+		TwoFactorIssuer:       getEnvDefault("TWO_FACTOR_ISSUER", "go-crud-api"),
+		TwoFactorChallengeTTL: twoFactorChallengeTTL,
+		TOTPEncryptionKey:     totpEncryptionKey,
+		LoginFailureWindow:    loginFailureWindow,
+		LoginDelayAfter:       loginDelayAfter,
+		LoginMaxFailures:      loginMaxFailures,
+		LoginLockoutDuration:  loginLockoutDuration,
+		LoginIPMaxFailures:    loginIPMaxFailures,
+		ImpersonationTTL:      impersonationTTL,
+	}
+
+	// Загружаем настройки почты
+	smtpPort, err := getEnvAsIntDefault("SMTP_PORT", 587)
+	if err != nil {
+		return nil, err
+	}
+
+	mailConfig := MailConfig{
+		Driver:       getEnvDefault("MAIL_DRIVER", "log"),
+		From:         getEnvDefault("MAIL_FROM", "no-reply@localhost"),
+		LogFile:      getEnvDefault("MAIL_LOG_FILE", "mail.log"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     smtpPort,
+		SMTPUser:     os.Getenv("SMTP_USER"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+	}
+
+	// Загружаем настройки OIDC
+	oidcStateTTL, err := getEnvAsDuration("OIDC_STATE_TTL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcAutoProvision, err := getEnvAsBool("OIDC_AUTO_PROVISION", true)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcScopes := getEnvAsList("OIDC_SCOPES")
+	if len(oidcScopes) == 0 {
+		oidcScopes = []string{"email", "profile"}
+	}
+
+	oidcConfig := OIDCConfig{
+		IssuerURL:     os.Getenv("OIDC_ISSUER_URL"),
+		ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:   getEnvDefault("OIDC_REDIRECT_URL", strings.TrimRight(authConfig.PublicURL, "/")+"/auth/oidc/callback"),
+		Scopes:        oidcScopes,
+		AutoProvision: oidcAutoProvision,
+		StateTTL:      oidcStateTTL,
+	}
+	if oidcConfig.Enabled() && oidcConfig.ClientID == "" {
+		return nil, fmt.Errorf("environment variable OIDC_CLIENT_ID is required when OIDC_ISSUER_URL is set")
+	}
+
+	// Загружаем политику паролей
+	passwordConfig, err := loadPasswordConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Загружаем настройки трассировки
+	tracingConfig, err := loadTracingConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cursorSecret, err := loadCursorSecret(jwtConfig.Secret)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Config{
 		DB:     dbConfig,
 		Server: serverConfig,
 		JWT:    jwtConfig,
+		Idempotency: IdempotencyConfig{
+			TTL: idempotencyTTL,
+		},
+		Pagination: PaginationConfig{
+			CursorSecret: cursorSecret,
+		},
+		Auth:     authConfig,
+		Mail:     mailConfig,
+		OIDC:     oidcConfig,
+		Password: *passwordConfig,
+		Tracing:  *tracingConfig,
 	}, nil
 }
 
+// loadTracingConfig загружает настройки трассировки
+func loadTracingConfig() (*TracingConfig, error) {
+	cfg := &TracingConfig{
+		Exporter:    getEnvDefault("TRACING_EXPORTER", "none"),
+		File:        getEnvDefault("TRACING_FILE", "traces.json"),
+		ServiceName: getEnvDefault("TRACING_SERVICE_NAME", "go-crud-api"),
+	}
+	switch cfg.Exporter {
+	case "none", "otlp", "stdout", "file":
+	default:
+		return nil, fmt.Errorf("unsupported TRACING_EXPORTER: %s", cfg.Exporter)
+	}
+
+	sampleRatio, err := getEnvAsFloat("TRACING_SAMPLE_RATIO", 1)
+	if err != nil {
+		return nil, err
+	}
+	if sampleRatio < 0 || sampleRatio > 1 {
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+	cfg.SampleRatio = sampleRatio
+
+	return cfg, nil
+}
+
+// loadCursorSecret загружает ключ подписи курсоров keyset-пагинации
+// Курсоры видны клиентам, поэтому их подписывает отдельный ключ, а не ключ подписи JWT
+func loadCursorSecret(jwtSecret string) (string, error) {
+	secret := os.Getenv("CURSOR_SECRET")
+	if secret == "" {
+		return "", fmt.Errorf("environment variable CURSOR_SECRET is required")
+	}
+	if secret == jwtSecret {
+		return "", fmt.Errorf("environment variable CURSOR_SECRET must differ from JWT_SECRET")
+	}
+	return secret, nil
+}
+
+// loadPasswordConfig загружает политику паролей и параметры хеширования
+func loadPasswordConfig() (*PasswordConfig, error) {
+	cfg := &PasswordConfig{
+		Algorithm:        getEnvDefault("PASSWORD_ALGORITHM", "bcrypt"),
+		BreachedListFile: os.Getenv("PASSWORD_BREACHED_LIST_FILE"),
+	}
+	if cfg.Algorithm != "bcrypt" && cfg.Algorithm != "argon2id" {
+		return nil, fmt.Errorf("unsupported PASSWORD_ALGORITHM: %s", cfg.Algorithm)
+	}
+
+	ints := []struct {
+		key          string
+		defaultValue int
+		target       *int
+	}{
+		{"PASSWORD_BCRYPT_COST", 10, &cfg.BcryptCost},
+		{"PASSWORD_MIN_LENGTH", 8, &cfg.MinLength},
+		{"PASSWORD_MAX_LENGTH", 72, &cfg.MaxLength},
+		{"PASSWORD_HISTORY", 5, &cfg.HistorySize},
+	}
+	for _, item := range ints {
+		value, err := getEnvAsIntDefault(item.key, item.defaultValue)
+		if err != nil {
+			return nil, err
+		}
+		*item.target = value
+	}
+
+	bools := []struct {
+		key    string
+		target *bool
+	}{
+		{"PASSWORD_REQUIRE_UPPER", &cfg.RequireUpper},
+		{"PASSWORD_REQUIRE_LOWER", &cfg.RequireLower},
+		{"PASSWORD_REQUIRE_DIGIT", &cfg.RequireDigit},
+		{"PASSWORD_REQUIRE_SYMBOL", &cfg.RequireSymbol},
+	}
+	for _, item := range bools {
+		value, err := getEnvAsBool(item.key, false)
+		if err != nil {
+			return nil, err
+		}
+		*item.target = value
+	}
+
+	// bcrypt молча заменяет слишком малую стоимость на DefaultCost, а слишком большую
+	// отклоняет только при первом хешировании, поэтому проверяем диапазон при запуске
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("PASSWORD_BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	argon2Time, err := getEnvAsIntDefault("PASSWORD_ARGON2_TIME", 3)
+	if err != nil {
+		return nil, err
+	}
+	argon2Memory, err := getEnvAsIntDefault("PASSWORD_ARGON2_MEMORY", 64*1024)
+	if err != nil {
+		return nil, err
+	}
+	argon2Threads, err := getEnvAsIntDefault("PASSWORD_ARGON2_THREADS", 2)
+	if err != nil {
+		return nil, err
+	}
+	if argon2Time < 1 || argon2Memory < 8*argon2Threads || argon2Threads < 1 || argon2Threads > 255 {
+		return nil, fmt.Errorf("invalid argon2id parameters")
+	}
+	cfg.Argon2Time = uint32(argon2Time)
+	cfg.Argon2Memory = uint32(argon2Memory)
+	cfg.Argon2Threads = uint8(argon2Threads)
+
+	if cfg.MinLength < 1 || cfg.MaxLength < cfg.MinLength {
+		return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH/PASSWORD_MAX_LENGTH")
+	}
+
+	return cfg, nil
+}
+
 // GetDSN возвращает строку подключения к PostgreSQL
 func (c *DBConfig) GetDSN() string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
@@ -97,6 +570,16 @@ func getEnv(key string) string {
 	return value
 }
 
+// getEnvDefault получает значение переменной окружения
+// Возвращает значение по умолчанию если переменная не задана
+func getEnvDefault(key string, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
 // getEnvAsInt получает целочисленное значение переменной окружения
 // Возвращает ошибку если переменная не задана или не является числом
 func getEnvAsInt(key string) (int, error) {
@@ -107,3 +590,71 @@ func getEnvAsInt(key string) (int, error) {
 	}
 	return value, nil
 }
+
+// getEnvAsDuration получает длительность из переменной окружения (например, 15m, 720h)
+// Возвращает значение по умолчанию если переменная не задана
+func getEnvAsDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	value, err := time.ParseDuration(valueStr)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable %s is not a valid duration: %v", key, err)
+	}
+	return value, nil
+}
+
+// getEnvAsIntDefault получает целочисленное значение переменной окружения
+// Возвращает значение по умолчанию если переменная не задана
+func getEnvAsIntDefault(key string, defaultValue int) (int, error) {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable %s is not a valid integer: %v", key, err)
+	}
+	return value, nil
+}
+
+// getEnvAsFloat получает дробное значение переменной окружения
+// Возвращает значение по умолчанию если переменная не задана
+func getEnvAsFloat(key string, defaultValue float64) (float64, error) {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable %s is not a valid number: %v", key, err)
+	}
+	return value, nil
+}
+
+// getEnvAsBool получает логическое значение переменной окружения (true/false, 1/0)
+// Возвращает значение по умолчанию если переменная не задана
+func getEnvAsBool(key string, defaultValue bool) (bool, error) {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return false, fmt.Errorf("environment variable %s is not a valid boolean: %v", key, err)
+	}
+	return value, nil
+}
+
+// getEnvAsList получает список значений, разделенных запятыми
+// Возвращает пустой список если переменная не задана
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
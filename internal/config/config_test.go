@@ -0,0 +1,67 @@
+package config
+
+import "testing"
+
+func TestLoadPasswordConfigBcryptCost(t *testing.T) {
+	tests := []struct {
+		cost    string
+		wantErr bool
+	}{
+		{"", false},
+		{"4", false},
+		{"12", false},
+		{"31", false},
+		{"3", true},
+		{"0", true},
+		{"-1", true},
+		{"32", true},
+		{"ten", true},
+	}
+	for _, tt := range tests {
+		t.Run("cost="+tt.cost, func(t *testing.T) {
+			t.Setenv("PASSWORD_BCRYPT_COST", tt.cost)
+
+			cfg, err := loadPasswordConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got cost %d", cfg.BcryptCost)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadCursorSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{"separate secret", "cursor-secret", false},
+		{"not set", "", true},
+		{"same as JWT secret", "jwt-secret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CURSOR_SECRET", tt.secret)
+
+			secret, err := loadCursorSecret("jwt-secret")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got secret %q", secret)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if secret != tt.secret {
+				t.Fatalf("got %q, want %q", secret, tt.secret)
+			}
+		})
+	}
+}
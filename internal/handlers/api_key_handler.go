@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/services"
+	"go-crud-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler обрабатывает запросы управления персональными ключами API
+// Права доступа к ключам пользователя проверяются через middleware
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// CreateAPIKey выпускает новый ключ API для пользователя из URL
+// Ключ возвращается только в этом ответе, сохранить его нужно сразу
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.apiKeyService.WithContext(c.Request.Context()).Create(uint(userID), req)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "CreateAPIKey", err)
+		switch {
+		case errors.Is(err, services.ErrInvalidAPIKeyExpiry):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err.Error() == "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create api key"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListAPIKeys возвращает ключи пользователя из URL без секретов
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	keys, err := h.apiKeyService.WithContext(c.Request.Context()).List(uint(userID))
+	if err != nil {
+		utils.LogError(c.Request.Context(), "ListAPIKeys", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list api keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey отзывает ключ пользователя
+// Возвращает 204 No Content при успешном отзыве
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("key_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return
+	}
+
+	if err := h.apiKeyService.WithContext(c.Request.Context()).Revoke(uint(userID), uint(keyID)); err != nil {
+		utils.LogError(c.Request.Context(), "RevokeAPIKey", err)
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke api key"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
+	"go-crud-api/internal/middleware"
 	"go-crud-api/internal/models"
 	"go-crud-api/internal/services"
 	"go-crud-api/internal/utils"
@@ -13,16 +17,32 @@ import (
 // AuthHandler обрабатывает запросы, связанные с аутентификацией
 // (регистрация и вход в систему)
 type AuthHandler struct {
-	userService *services.UserService
+	userService          *services.UserService
+	authService          *services.AuthService
+	passwordResetService *services.PasswordResetService
+	verificationService  *services.EmailVerificationService
+	twoFactorService     *services.TwoFactorService
+	oidcService          *services.OIDCService
 }
 
-func NewAuthHandler(userService *services.UserService) *AuthHandler {
-	return &AuthHandler{userService: userService}
+// oidcStateCookie - cookie, в которой хранится state начатого входа через OIDC
+const oidcStateCookie = "oidc_state"
+
+func NewAuthHandler(userService *services.UserService, authService *services.AuthService, passwordResetService *services.PasswordResetService, verificationService *services.EmailVerificationService, twoFactorService *services.TwoFactorService, oidcService *services.OIDCService) *AuthHandler {
+	return &AuthHandler{
+		userService:          userService,
+		authService:          authService,
+		passwordResetService: passwordResetService,
+		verificationService:  verificationService,
+		twoFactorService:     twoFactorService,
+		oidcService:          oidcService,
+	}
 }
 
 // Register создает нового пользователя
 // Принимает email, пароль и другие данные пользователя
-// Возвращает созданного пользователя или ошибку
+// Пользователь попадает в организацию по умолчанию.
+// Возвращает созданного пользователя или ошибку, на email отправляется ссылка для подтверждения
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -30,18 +50,26 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.CreateUser(req)
+	// Регистрация доступна без токена, поэтому организацию не выбирает клиент:
+	// пользователей других организаций создают их администраторы через POST /users
+	user, err := h.userService.WithContext(c.Request.Context()).ForOrg(models.DefaultOrgID).CreateUser(req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Ошибка отправки письма не отменяет регистрацию: письмо можно запросить повторно
+	if err := h.verificationService.WithContext(c.Request.Context()).SendVerification(user.ID); err != nil {
+		utils.LogError(c.Request.Context(), "Register", err)
+	}
+
 	c.JSON(http.StatusCreated, user)
 }
 
 // Login аутентифицирует пользователя
-// Проверяет email и пароль, генерирует JWT токен
-// Возвращает токен и данные пользователя
+// Проверяет email и пароль, открывает новую сессию
+// Возвращает короткоживущий access-токен и refresh-токен.
+// Если у пользователя включена 2FA, возвращает mfa-токен для второго шага (LoginTwoFactor)
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req struct {
 		Email    string `json:"email" binding:"required,email"`
@@ -49,28 +77,307 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.LogError("Login", err)
+		utils.LogError(c.Request.Context(), "Login", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.WithContext(c.Request.Context()).Login(req.Email, req.Password, c.ClientIP())
+	if err != nil {
+		var throttled *services.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": throttled.Error()})
+		case errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+		default:
+			utils.LogError(c.Request.Context(), "Login", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+		}
+		return
+	}
+
+	h.completeLogin(c, user, "Login")
+}
+
+// OIDCLogin начинает вход через внешнего провайдера OpenID Connect
+// Перенаправляет пользователя на страницу входа провайдера
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	authURL, state, err := h.oidcService.WithContext(c.Request.Context()).Begin()
+	if err != nil {
+		utils.LogError(c.Request.Context(), "OIDCLogin", err)
+		if errors.Is(err, services.ErrOIDCDisabled) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider is unavailable"})
+		}
+		return
+	}
+
+	// state дублируется в cookie, чтобы callback принимался только в том же браузере
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(h.oidcService.StateTTL().Seconds()), "/auth/oidc", "", h.oidcService.SecureCookie(), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback завершает вход через провайдера OpenID Connect
+// Обменивает code на данные пользователя и выдает собственные токены приложения
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": providerErr, "error_description": c.Query("error_description")})
+		return
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	cookieState, _ := c.Cookie(oidcStateCookie)
+	if state == "" || code == "" || cookieState != state {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidOIDCState.Error()})
+		return
+	}
+	c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", h.oidcService.SecureCookie(), true)
+
+	user, err := h.oidcService.WithContext(c.Request.Context()).Complete(state, code)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "OIDCCallback", err)
+		switch {
+		case errors.Is(err, services.ErrOIDCDisabled):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidOIDCState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrOIDCLoginFailed):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": services.ErrOIDCLoginFailed.Error()})
+		case errors.Is(err, services.ErrOIDCAccountNotLinked),
+			errors.Is(err, services.ErrOIDCEmailConflict):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete oidc login"})
+		}
+		return
+	}
+
+	h.completeLogin(c, user, "OIDCLogin")
+}
+
+// completeLogin завершает вход уже аутентифицированного пользователя
+// Проверяет подтверждение email, при включенной 2FA выдает mfa-токен, иначе пару токенов
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, operation string) {
+	if err := h.verificationService.CheckLogin(user); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.twoFactorService.IsRequired(user) {
+		challenge, err := h.twoFactorService.WithContext(c.Request.Context()).StartLogin(user)
+		if err != nil {
+			utils.LogError(c.Request.Context(), operation, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor login"})
+			return
+		}
+
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	tokens, err := h.authService.WithContext(c.Request.Context()).IssueTokens(user, clientInfo(c))
+	if err != nil {
+		utils.LogError(c.Request.Context(), operation, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	utils.LogOperation(c.Request.Context(), operation, user.ID, "User logged in successfully")
+	c.JSON(http.StatusOK, tokens)
+}
+
+// LoginTwoFactor завершает вход с 2FA
+// Обменивает mfa-токен и код из приложения (или код восстановления) на пару токенов
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user, err := h.userService.Login(req.Email, req.Password)
+	user, err := h.twoFactorService.WithContext(c.Request.Context()).CompleteLogin(req.MFAToken, req.Code)
 	if err != nil {
-		utils.LogError("Login", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+		utils.LogError(c.Request.Context(), "LoginTwoFactor", err)
+		if errors.Is(err, services.ErrInvalidTwoFactorChallenge) || errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		}
 		return
 	}
 
-	// Генерируем JWT токен с временем жизни 24 часа
-	token, err := utils.GenerateToken(user.ID)
+	tokens, err := h.authService.WithContext(c.Request.Context()).IssueTokens(user, clientInfo(c))
 	if err != nil {
-		utils.LogError("Login", err)
+		utils.LogError(c.Request.Context(), "LoginTwoFactor", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	utils.LogOperation("Login", user.ID, "User logged in successfully")
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-	})
+	utils.LogOperation(c.Request.Context(), "Login", user.ID, "User logged in with two-factor authentication")
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов
+// Использованный refresh-токен становится недействительным
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.authService.WithContext(c.Request.Context()).Refresh(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Refresh", err)
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout завершает текущую сессию
+// Отзывает access-токен из запроса и все refresh-токены сессии
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	if err := h.authService.WithContext(c.Request.Context()).Logout(claims); err != nil {
+		utils.LogError(c.Request.Context(), "Logout", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	utils.LogOperation(c.Request.Context(), "Logout", claims.UserID, "User logged out successfully")
+	c.Status(http.StatusNoContent)
+}
+
+// ExchangeToken выдает пару токенов с меньшими областями доступа в обмен на текущий access-токен
+// Используется для интеграций, которым нужны только отдельные права (например, orders:read)
+func (h *AuthHandler) ExchangeToken(c *gin.Context) {
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	var req models.TokenExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.authService.WithContext(c.Request.Context()).Exchange(claims, req.Scopes, clientInfo(c))
+	if err != nil {
+		utils.LogError(c.Request.Context(), "ExchangeToken", err)
+		if errors.Is(err, services.ErrScopeNotGranted) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ForgotPassword отправляет письмо со ссылкой для сброса пароля
+// Всегда отвечает 202, чтобы нельзя было проверить, зарегистрирован ли email
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.passwordResetService.WithContext(c.Request.Context()).RequestReset(req.Email); err != nil {
+		utils.LogError(c.Request.Context(), "ForgotPassword", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword устанавливает новый пароль по токену из письма
+// После сброса все сессии пользователя завершаются
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.passwordResetService.WithContext(c.Request.Context()).ResetPassword(req.Token, req.Password); err != nil {
+		utils.LogError(c.Request.Context(), "ResetPassword", err)
+		if errors.Is(err, services.ErrInvalidResetToken) || services.IsPasswordPolicyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// VerifyEmail подтверждает email по токену из письма
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.verificationService.WithContext(c.Request.Context()).Verify(req.Token); err != nil {
+		utils.LogError(c.Request.Context(), "VerifyEmail", err)
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ResendVerification повторно отправляет письмо с подтверждением email
+// Всегда отвечает 202, чтобы нельзя было проверить, зарегистрирован ли email
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.verificationService.WithContext(c.Request.Context()).ResendByEmail(req.Email); err != nil {
+		utils.LogError(c.Request.Context(), "ResendVerification", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered and not verified, a verification link has been sent"})
+}
+
+// JWKS отдает открытые ключи для проверки access-токенов другими сервисами
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
+
+// clientInfo возвращает IP и User-Agent клиента для сохранения в сессии
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
 }
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler обрабатывает проверки состояния для оркестратора
+// /healthz сообщает, что процесс жив, /readyz - что он готов принимать запросы
+type HealthHandler struct {
+	healthService *services.HealthService
+}
+
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
+}
+
+// Liveness отвечает 200, пока процесс обрабатывает запросы
+// Зависимости не проверяются: их недоступность не лечится перезапуском
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, models.HealthResponse{Status: models.HealthStatusOK})
+}
+
+// Readiness проверяет БД и миграции
+// Возвращает 503 с перечнем непрошедших проверок, если приложение не готово
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	response, ready := h.healthService.Readiness(c.Request.Context())
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-crud-api/internal/middleware"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/services"
+	"go-crud-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationHandler обрабатывает вход сотрудников от имени пользователей и журнал таких входов
+// Права доступа проверяются через middleware
+type ImpersonationHandler struct {
+	impersonationService *services.ImpersonationService
+}
+
+func NewImpersonationHandler(impersonationService *services.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{impersonationService: impersonationService}
+}
+
+// Impersonate выдает короткоживущий токен для работы от имени пользователя из URL
+// Причина входа обязательна и сохраняется в журнале
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req models.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.impersonationService.WithContext(c.Request.Context()).Start(middleware.OrgID(c), c.GetUint("user_id"), c.GetString("role"), uint(userID), req.Reason, clientInfo(c))
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Impersonate", err)
+		switch {
+		case errors.Is(err, services.ErrImpersonatedUserMissing):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrImpersonationNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start impersonation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListImpersonations возвращает журнал входов от имени пользователей
+// Поддерживает фильтрацию по сотруднику (actor_id) и пользователю (user_id)
+func (h *ImpersonationHandler) ListImpersonations(c *gin.Context) {
+	var filter models.ImpersonationFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessions, err := h.impersonationService.WithContext(c.Request.Context()).List(middleware.OrgID(c), filter)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "ListImpersonations", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list impersonations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// GetImpersonation возвращает сессию из журнала со всеми выполненными запросами
+func (h *ImpersonationHandler) GetImpersonation(c *gin.Context) {
+	details, err := h.impersonationService.WithContext(c.Request.Context()).Get(middleware.OrgID(c), c.Param("sid"))
+	if err != nil {
+		utils.LogError(c.Request.Context(), "GetImpersonation", err)
+		if errors.Is(err, services.ErrImpersonationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get impersonation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"go-crud-api/internal/middleware"
 	"go-crud-api/internal/models"
 	"go-crud-api/internal/services"
+	"go-crud-api/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,8 +23,13 @@ func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
 	return &OrderHandler{orderService: orderService}
 }
 
-// CreateOrder создает новый заказ для текущего пользователя
-// ID пользователя берется из JWT токена через middleware
+// service возвращает сервис, ограниченный организацией и привязанный к контексту запроса
+func (h *OrderHandler) service(c *gin.Context) *services.OrderService {
+	return h.orderService.WithContext(c.Request.Context()).ForOrg(middleware.OrgID(c))
+}
+
+// CreateOrder создает новый заказ для пользователя из URL
+// Права доступа проверяются через middleware
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	// Получаем ID пользователя из URL
 	userIDFromURL, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -30,14 +38,8 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Получаем ID пользователя из JWT токена
-	userIDFromToken := c.GetUint("user_id")
-
-	// Проверяем, что ID из URL совпадает с ID из токена
-	if uint(userIDFromURL) != userIDFromToken {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return
-	}
+	// Доступ к заказам пользователя проверяется в middleware.RequireSelfOrRole
+	userID := uint(userIDFromURL)
 
 	var req models.CreateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -46,14 +48,14 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	}
 
 	order := &models.Order{
-		UserID:   userIDFromToken,
-		Product:  req.Product,
-		Quantity: req.Quantity,
-		Price:    req.Price,
+		UserID: userID,
+		Items:  models.NewOrderItems(req.Items),
 	}
 
-	if err := h.orderService.Create(order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// При входе от имени пользователя в истории статусов сохраняется сотрудник
+	if err := h.service(c).Create(order, middleware.ActorID(c)); err != nil {
+		utils.LogError(c.Request.Context(), "CreateOrder", err)
+		respondOrderError(c, err)
 		return
 	}
 
@@ -70,14 +72,8 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 		return
 	}
 
-	// Получаем ID пользователя из JWT токена
-	userIDFromToken := c.GetUint("user_id")
-
-	// Проверяем, что ID из URL совпадает с ID из токена
-	if uint(userIDFromURL) != userIDFromToken {
-		c.JSON(http.StatusForbidden, gin.H{"error": "нет доступа к заказам другого пользователя"})
-		return
-	}
+	// Доступ к заказам пользователя проверяется в middleware.RequireSelfOrRole
+	userID := uint(userIDFromURL)
 
 	// Получаем ID заказа
 	orderID, err := strconv.ParseUint(c.Param("order_id"), 10, 32)
@@ -86,14 +82,14 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.GetByID(uint(orderID))
+	order, err := h.service(c).GetByID(uint(orderID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "заказ не найден"})
 		return
 	}
 
 	// Проверяем принадлежность заказа пользователю
-	if order.UserID != userIDFromToken {
+	if order.UserID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "заказ принадлежит другому пользователю"})
 		return
 	}
@@ -101,8 +97,8 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	c.JSON(http.StatusOK, order)
 }
 
-// UpdateOrder обновляет существующий заказ
-// Проверяет принадлежность заказа текущему пользователю
+// UpdateOrder заменяет позиции существующего заказа
+// Проверяет принадлежность заказа пользователю из URL
 func (h *OrderHandler) UpdateOrder(c *gin.Context) {
 	// Получаем ID пользователя из URL
 	userIDFromURL, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -111,14 +107,8 @@ func (h *OrderHandler) UpdateOrder(c *gin.Context) {
 		return
 	}
 
-	// Получаем ID пользователя из JWT токена
-	userIDFromToken := c.GetUint("user_id")
-
-	// Проверяем, что ID из URL совпадает с ID из токена
-	if uint(userIDFromURL) != userIDFromToken {
-		c.JSON(http.StatusForbidden, gin.H{"error": "нет доступа к заказам другого пользователя"})
-		return
-	}
+	// Доступ к заказам пользователя проверяется в middleware.RequireSelfOrRole
+	userID := uint(userIDFromURL)
 
 	// Получаем ID заказа
 	orderID, err := strconv.ParseUint(c.Param("order_id"), 10, 32)
@@ -128,29 +118,33 @@ func (h *OrderHandler) UpdateOrder(c *gin.Context) {
 	}
 
 	// Проверяем существование заказа и принадлежность пользователю
-	existingOrder, err := h.orderService.GetByID(uint(orderID))
+	existingOrder, err := h.service(c).GetByID(uint(orderID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "заказ не найден"})
 		return
 	}
 
 	// Проверяем принадлежность заказа пользователю
-	if existingOrder.UserID != userIDFromToken {
+	if existingOrder.UserID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "заказ принадлежит другому пользователю"})
 		return
 	}
 
-	var order models.Order
-	if err := c.ShouldBindJSON(&order); err != nil {
+	var req models.UpdateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	order.ID = uint(orderID)
-	order.UserID = userIDFromToken
+	order := models.Order{
+		ID:     uint(orderID),
+		UserID: userID,
+		Items:  models.NewOrderItems(req.Items),
+	}
 
-	if err := h.orderService.Update(&order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.service(c).Update(&order); err != nil {
+		utils.LogError(c.Request.Context(), "UpdateOrder", err)
+		respondOrderError(c, err)
 		return
 	}
 
@@ -167,14 +161,8 @@ func (h *OrderHandler) DeleteOrder(c *gin.Context) {
 		return
 	}
 
-	// Получаем ID пользователя из JWT токена
-	userIDFromToken := c.GetUint("user_id")
-
-	// Проверяем, что ID из URL совпадает с ID из токена
-	if uint(userIDFromURL) != userIDFromToken {
-		c.JSON(http.StatusForbidden, gin.H{"error": "нет доступа к заказам другого пользователя"})
-		return
-	}
+	// Доступ к заказам пользователя проверяется в middleware.RequireSelfOrRole
+	userID := uint(userIDFromURL)
 
 	// Получаем ID заказа
 	orderID, err := strconv.ParseUint(c.Param("order_id"), 10, 32)
@@ -184,28 +172,29 @@ func (h *OrderHandler) DeleteOrder(c *gin.Context) {
 	}
 
 	// Проверяем существование заказа и принадлежность пользователю
-	existingOrder, err := h.orderService.GetByID(uint(orderID))
+	existingOrder, err := h.service(c).GetByID(uint(orderID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "заказ не найден"})
 		return
 	}
 
 	// Проверяем принадлежность заказа пользователю
-	if existingOrder.UserID != userIDFromToken {
+	if existingOrder.UserID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "заказ принадлежит другому пользователю"})
 		return
 	}
 
-	if err := h.orderService.Delete(uint(orderID)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.service(c).Delete(uint(orderID)); err != nil {
+		utils.LogError(c.Request.Context(), "DeleteOrder", err)
+		respondOrderError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
-// GetUserOrders получает все заказы текущего пользователя
-// Использует ID пользователя из JWT токена
+// GetUserOrders получает страницу заказов пользователя из URL
+// Поддерживает фильтры и сортировку, права доступа проверяются через middleware
 func (h *OrderHandler) GetUserOrders(c *gin.Context) {
 	// Получаем ID пользователя из URL
 	userIDFromURL, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -214,20 +203,179 @@ func (h *OrderHandler) GetUserOrders(c *gin.Context) {
 		return
 	}
 
-	// Получаем ID пользователя из JWT токена
-	userIDFromToken := c.GetUint("user_id")
+	// Доступ к заказам пользователя проверяется в middleware.RequireSelfOrRole
+	userID := uint(userIDFromURL)
 
-	// Проверяем, что ID из URL совпадает с ID из токена
-	if uint(userIDFromURL) != userIDFromToken {
-		c.JSON(http.StatusForbidden, gin.H{"error": "нет доступа к заказам другого пользователя"})
+	var params models.OrderListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	orders, err := h.orderService.GetByUserID(userIDFromToken)
+	// Параметр cursor включает keyset-пагинацию
+	if _, ok := c.GetQuery("cursor"); ok {
+		response, err := h.service(c).ListByUserCursor(userID, params)
+		if err != nil {
+			respondOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response, err := h.service(c).ListByUser(userID, params)
 	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListOrders возвращает страницу всех заказов
+// Маршрут доступен только администраторам, поддерживает фильтр по user_id
+func (h *OrderHandler) ListOrders(c *gin.Context) {
+	var params models.OrderListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, orders)
+	// Параметр cursor включает keyset-пагинацию
+	if _, ok := c.GetQuery("cursor"); ok {
+		response, err := h.service(c).ListByCursor(params)
+		if err != nil {
+			respondOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response, err := h.service(c).List(params)
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PayOrder переводит заказ в статус paid
+// Маршрут доступен только сотрудникам, подтверждающим оплату
+func (h *OrderHandler) PayOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusPaid)
+}
+
+// CancelOrder отменяет заказ
+// Отменить можно только заказ, который еще не передан в доставку
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusCancelled)
+}
+
+// ShipOrder переводит заказ в статус shipped
+func (h *OrderHandler) ShipOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusShipped)
+}
+
+// DeliverOrder переводит заказ в статус delivered
+func (h *OrderHandler) DeliverOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusDelivered)
+}
+
+// RefundOrder переводит заказ в статус refunded
+func (h *OrderHandler) RefundOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusRefunded)
+}
+
+// GetOrderHistory возвращает историю статусов заказа
+func (h *OrderHandler) GetOrderHistory(c *gin.Context) {
+	userID, orderID, ok := parseOrderParams(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.service(c).GetStatusHistory(userID, orderID)
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// transitionOrder переводит заказ из URL в статус to
+// Допустимость перехода проверяется в OrderService
+func (h *OrderHandler) transitionOrder(c *gin.Context, to string) {
+	userID, orderID, ok := parseOrderParams(c)
+	if !ok {
+		return
+	}
+
+	// Тело запроса опционально
+	var req models.OrderTransitionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	order, err := h.service(c).Transition(userID, orderID, to, middleware.ActorID(c), req.Comment)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "TransitionOrder", err)
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// parseOrderParams получает ID пользователя и ID заказа из URL
+// При ошибке сразу отправляет ответ 400
+func parseOrderParams(c *gin.Context) (uint, uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID пользователя"})
+		return 0, 0, false
+	}
+
+	orderID, err := strconv.ParseUint(c.Param("order_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный ID заказа"})
+		return 0, 0, false
+	}
+
+	return uint(userID), uint(orderID), true
+}
+
+// respondOrderError преобразует ошибку OrderService в HTTP-ответ
+// Неизвестные ошибки считаются ошибками сервера: клиент получает общее сообщение, подробности пишутся в лог
+func respondOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "заказ не найден"})
+	case errors.Is(err, services.ErrOrderNotOwned):
+		c.JSON(http.StatusForbidden, gin.H{"error": "заказ принадлежит другому пользователю"})
+	case errors.Is(err, services.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTransition),
+		errors.Is(err, services.ErrOrderNotModifiable),
+		errors.Is(err, services.ErrOrderNotDeletable),
+		errors.Is(err, services.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProductUnavailable),
+		errors.Is(err, services.ErrCurrencyMismatch),
+		errors.Is(err, models.ErrMoneyOverflow):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidListParams):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err.Error() == "user not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	default:
+		utils.LogError(c.Request.Context(), "OrderRequest", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "внутренняя ошибка сервера"})
+	}
 }
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/services"
+	"go-crud-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationHandler обрабатывает запросы к списку организаций
+// Доступен только администраторам платформы
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+}
+
+func NewOrganizationHandler(organizationService *services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{organizationService: organizationService}
+}
+
+// CreateOrganization создает новую организацию
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.organizationService.WithContext(c.Request.Context()).Create(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidOrganizationSlug):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrOrganizationSlugTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			utils.LogError(c.Request.Context(), "CreateOrganization", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create organization"})
+		}
+		return
+	}
+
+	utils.LogOperation(c.Request.Context(), "CreateOrganization", c.GetUint("user_id"), "Organization created: "+org.Slug)
+	c.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations возвращает все организации
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	orgs, err := h.organizationService.WithContext(c.Request.Context()).List()
+	if err != nil {
+		utils.LogError(c.Request.Context(), "ListOrganizations", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, orgs)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/services"
+	"go-crud-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProductHandler обрабатывает запросы к каталогу товаров
+// Просмотр каталога публичный, изменение доступно только администраторам
+type ProductHandler struct {
+	productService *services.ProductService
+}
+
+func NewProductHandler(productService *services.ProductService) *ProductHandler {
+	return &ProductHandler{productService: productService}
+}
+
+// ListProducts возвращает активные товары с пагинацией
+// Поддерживает поиск через параметр q
+func (h *ProductHandler) ListProducts(c *gin.Context) {
+	var params models.ProductListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Устанавливаем значения по умолчанию
+	if params.Page == 0 {
+		params.Page = 1
+	}
+	if params.Limit == 0 {
+		params.Limit = 10
+	}
+
+	response, err := h.productService.WithContext(c.Request.Context()).List(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetProduct возвращает активный товар по ID
+func (h *ProductHandler) GetProduct(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	product, err := h.productService.WithContext(c.Request.Context()).GetByID(uint(id), false)
+	if err != nil {
+		respondProductError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// CreateProduct добавляет товар в каталог
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	var req models.CreateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError(c.Request.Context(), "CreateProduct", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.productService.WithContext(c.Request.Context()).Create(req)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "CreateProduct", err)
+		respondProductError(c, err)
+		return
+	}
+
+	utils.LogOperation(c.Request.Context(), "CreateProduct", c.GetUint("user_id"), "Product "+strconv.FormatUint(uint64(product.ID), 10)+" created")
+	c.JSON(http.StatusCreated, product)
+}
+
+// UpdateProduct обновляет товар
+// Позволяет в том числе деактивировать товар
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	var req models.UpdateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError(c.Request.Context(), "UpdateProduct", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.productService.WithContext(c.Request.Context()).Update(uint(id), req)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "UpdateProduct", err)
+		respondProductError(c, err)
+		return
+	}
+
+	utils.LogOperation(c.Request.Context(), "UpdateProduct", c.GetUint("user_id"), "Product "+strconv.FormatUint(uint64(product.ID), 10)+" updated")
+	c.JSON(http.StatusOK, product)
+}
+
+// DeleteProduct удаляет товар из каталога
+// Возвращает 204 No Content при успешном удалении
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	if err := h.productService.WithContext(c.Request.Context()).Delete(uint(id)); err != nil {
+		utils.LogError(c.Request.Context(), "DeleteProduct", err)
+		respondProductError(c, err)
+		return
+	}
+
+	utils.LogOperation(c.Request.Context(), "DeleteProduct", c.GetUint("user_id"), "Product "+strconv.FormatUint(id, 10)+" deleted")
+	c.Status(http.StatusNoContent)
+}
+
+// LowStockReport возвращает товары, которые заканчиваются на складе
+// Порог задается параметром threshold (по умолчанию 5)
+func (h *ProductHandler) LowStockReport(c *gin.Context) {
+	params := models.LowStockParams{Threshold: 5}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	products, err := h.productService.WithContext(c.Request.Context()).LowStock(params.Threshold)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, products)
+}
+
+// respondProductError преобразует ошибку ProductService в HTTP-ответ
+func respondProductError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrProductNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidPrice) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-crud-api/internal/middleware"
+	"go-crud-api/internal/services"
+	"go-crud-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler обрабатывает запросы управления сессиями (устройствами) пользователя
+// Права доступа к сессиям пользователя проверяются через middleware
+type SessionHandler struct {
+	authService *services.AuthService
+}
+
+func NewSessionHandler(authService *services.AuthService) *SessionHandler {
+	return &SessionHandler{authService: authService}
+}
+
+// ListSessions возвращает активные сессии пользователя из URL
+// Сессия текущего запроса отмечена полем current
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	sessions, err := h.authService.WithContext(c.Request.Context()).ListSessions(uint(userID), currentSessionID(c))
+	if err != nil {
+		utils.LogError(c.Request.Context(), "ListSessions", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession завершает сессию пользователя
+// Возвращает 204 No Content при успешном завершении
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.authService.WithContext(c.Request.Context()).RevokeSession(uint(userID), c.Param("sid")); err != nil {
+		utils.LogError(c.Request.Context(), "RevokeSession", err)
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions завершает все сессии пользователя, кроме текущей
+// Если запрос выполняет другой пользователь (администратор), завершаются все сессии
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.authService.WithContext(c.Request.Context()).RevokeOtherSessions(uint(userID), currentSessionID(c)); err != nil {
+		utils.LogError(c.Request.Context(), "RevokeOtherSessions", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// currentSessionID возвращает ID сессии текущего запроса, если запрос выполнен
+// владельцем сессий из URL. Иначе возвращает пустую строку
+func currentSessionID(c *gin.Context) string {
+	claims, ok := middleware.GetClaims(c)
+	if !ok || strconv.FormatUint(uint64(claims.UserID), 10) != c.Param("id") {
+		return ""
+	}
+	return claims.SessionID
+}
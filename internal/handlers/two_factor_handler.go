@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/services"
+	"go-crud-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler обрабатывает запросы управления двухфакторной аутентификацией
+// Все маршруты работают с текущим пользователем из токена
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+}
+
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{twoFactorService: twoFactorService}
+}
+
+// Enroll создает секрет TOTP и otpauth URI для приложения-аутентификатора
+// 2FA включается только после подтверждения кодом через Confirm
+func (h *TwoFactorHandler) Enroll(c *gin.Context) {
+	response, err := h.twoFactorService.WithContext(c.Request.Context()).Enroll(c.GetUint("user_id"))
+	if err != nil {
+		utils.LogError(c.Request.Context(), "TwoFactorEnroll", err)
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Confirm включает 2FA по первому коду из приложения
+// Возвращает коды восстановления
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.twoFactorService.WithContext(c.Request.Context()).Confirm(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "TwoFactorConfirm", err)
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Disable выключает 2FA
+// Требует код из приложения или код восстановления
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.twoFactorService.WithContext(c.Request.Context()).Disable(c.GetUint("user_id"), req.Code); err != nil {
+		utils.LogError(c.Request.Context(), "TwoFactorDisable", err)
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes выдает новый набор кодов восстановления
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.twoFactorService.WithContext(c.Request.Context()).RegenerateRecoveryCodes(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "TwoFactorRecoveryCodes", err)
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// respondTwoFactorError преобразует ошибку TwoFactorService в HTTP-ответ
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, services.ErrTwoFactorNotEnabled),
+		errors.Is(err, services.ErrTwoFactorNotEnrolled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process two-factor request"})
+	}
+}
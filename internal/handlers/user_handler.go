@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"go-crud-api/internal/middleware"
 	"go-crud-api/internal/models"
 	"go-crud-api/internal/services"
 	"go-crud-api/internal/utils"
@@ -12,29 +14,38 @@ import (
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService         *services.UserService
+	verificationService *services.EmailVerificationService
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService *services.UserService, verificationService *services.EmailVerificationService) *UserHandler {
+	return &UserHandler{
+		userService:         userService,
+		verificationService: verificationService,
+	}
+}
+
+// service возвращает сервис, ограниченный организацией и привязанный к контексту запроса
+func (h *UserHandler) service(c *gin.Context) *services.UserService {
+	return h.userService.WithContext(c.Request.Context()).ForOrg(middleware.OrgID(c))
 }
 
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.LogError("CreateUser", err)
+		utils.LogError(c.Request.Context(), "CreateUser", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user, err := h.userService.CreateUser(req)
+	user, err := h.service(c).CreateUser(req)
 	if err != nil {
-		utils.LogError("CreateUser", err)
+		utils.LogError(c.Request.Context(), "CreateUser", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	utils.LogOperation("CreateUser", user.ID, "User created successfully")
+	utils.LogOperation(c.Request.Context(), "CreateUser", user.ID, "User created successfully")
 	c.JSON(http.StatusCreated, user)
 }
 
@@ -53,7 +64,23 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		params.Limit = 10
 	}
 
-	response, err := h.userService.ListUsers(params)
+	// Параметр cursor включает keyset-пагинацию
+	if _, ok := c.GetQuery("cursor"); ok {
+		response, err := h.service(c).ListUsersByCursor(params)
+		if err != nil {
+			if errors.Is(err, utils.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response, err := h.service(c).ListUsers(params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -69,7 +96,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUser(uint(id))
+	user, err := h.service(c).GetUser(uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
@@ -81,22 +108,30 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		utils.LogError("UpdateUser", err)
+		utils.LogError(c.Request.Context(), "UpdateUser", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		return
 	}
 
 	var req models.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.LogError("UpdateUser", err)
+		utils.LogError(c.Request.Context(), "UpdateUser", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user, err := h.userService.UpdateUser(uint(id), req)
+	// Сотрудник, вошедший от имени пользователя, не может менять его учетные данные
+	if middleware.IsImpersonating(c) && (req.Password != "" || req.Email != "") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this action is not allowed while impersonating"})
+		return
+	}
+
+	user, err := h.service(c).UpdateUser(uint(id), req, c.GetString("role"), currentSessionID(c))
 	if err != nil {
-		utils.LogError("UpdateUser", err)
-		if err.Error() == "user not found" {
+		utils.LogError(c.Request.Context(), "UpdateUser", err)
+		if errors.Is(err, services.ErrRoleChangeForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		} else if err.Error() == "user not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -104,21 +139,62 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	utils.LogOperation("UpdateUser", user.ID, "User updated successfully")
+	// При смене email подтверждение сбрасывается, отправляем письмо на новый адрес
+	if req.Email != "" && user.EmailVerifiedAt == nil {
+		if err := h.verificationService.WithContext(c.Request.Context()).SendVerification(user.ID); err != nil {
+			utils.LogError(c.Request.Context(), "UpdateUser", err)
+		}
+	}
+
+	utils.LogOperation(c.Request.Context(), "UpdateUser", user.ID, "User updated successfully")
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateRole меняет роль пользователя
+// Маршрут доступен только администраторам
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "UpdateRole", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError(c.Request.Context(), "UpdateRole", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service(c).UpdateRole(uint(id), req.Role, c.GetString("role"))
+	if err != nil {
+		utils.LogError(c.Request.Context(), "UpdateRole", err)
+		if errors.Is(err, services.ErrRoleChangeForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	utils.LogOperation(c.Request.Context(), "UpdateRole", user.ID, "Role changed to "+user.Role+" by user "+strconv.FormatUint(uint64(c.GetUint("user_id")), 10))
 	c.JSON(http.StatusOK, user)
 }
 
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		utils.LogError("DeleteUser", err)
+		utils.LogError(c.Request.Context(), "DeleteUser", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		return
 	}
 
-	if err := h.userService.DeleteUser(uint(id)); err != nil {
-		utils.LogError("DeleteUser", err)
-		if err.Error() == "user not found" {
+	if err := h.service(c).DeleteUser(uint(id), c.GetString("role")); err != nil {
+		utils.LogError(c.Request.Context(), "DeleteUser", err)
+		if errors.Is(err, services.ErrRoleChangeForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		} else if err.Error() == "user not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -126,6 +202,26 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	utils.LogOperation("DeleteUser", uint(id), "User deleted successfully")
+	utils.LogOperation(c.Request.Context(), "DeleteUser", uint(id), "User deleted successfully")
 	c.Status(http.StatusNoContent)
 }
+
+// UnlockUser снимает блокировку входа после неудачных попыток
+// Маршрут доступен только администраторам
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	user, err := h.service(c).Unlock(uint(id))
+	if err != nil {
+		utils.LogError(c.Request.Context(), "UnlockUser", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	utils.LogOperation(c.Request.Context(), "UnlockUser", user.ID, "Unlocked by user "+strconv.FormatUint(uint64(c.GetUint("user_id")), 10))
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogSender записывает письма в файл вместо отправки
+// Используется при локальной разработке: ссылки из писем можно взять прямо из файла
+type LogSender struct {
+	path string
+	from string
+	mu   sync.Mutex
+}
+
+func NewLogSender(path, from string) *LogSender {
+	if path == "" {
+		path = "mail.log"
+	}
+	return &LogSender{path: path, from: from}
+}
+
+// Send дописывает письмо в конец файла
+func (s *LogSender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "==== %s\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), s.from, msg.To, msg.Subject, msg.Body)
+	return err
+}
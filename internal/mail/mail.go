@@ -0,0 +1,33 @@
+package mail
+
+import (
+	"fmt"
+
+	"go-crud-api/internal/config"
+)
+
+// Message представляет письмо пользователю
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender отправляет письма
+// Реализация выбирается в конфигурации (MAIL_DRIVER)
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSender создает отправителя писем по настройкам
+// log - письма записываются в файл (для локальной разработки), smtp - отправляются через SMTP сервер
+func NewSender(cfg config.MailConfig) (Sender, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return NewLogSender(cfg.LogFile, cfg.From), nil
+	case "smtp":
+		return NewSMTPSender(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Driver)
+	}
+}
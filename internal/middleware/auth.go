@@ -4,15 +4,51 @@ import (
 	"net/http"
 	"strings"
 
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/services"
 	"go-crud-api/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware проверяет JWT токен в заголовке Authorization
-// Добавляет ID пользователя в контекст запроса
-func AuthMiddleware() gin.HandlerFunc {
+// APIKeyHeader - заголовок, в котором передается персональный ключ API
+const APIKeyHeader = "X-API-Key"
+
+// Способы аутентификации запроса
+const (
+	AuthMethodToken  = "token"
+	AuthMethodAPIKey = "api_key"
+)
+
+// AuthMiddleware проверяет JWT токен в заголовке Authorization или ключ API в заголовке X-API-Key
+// Отклоняет отозванные токены и токены завершенных сессий, отозванные и просроченные ключи
+// Добавляет ID, роль и организацию пользователя и данные токена (или ключа) в контекст запроса
+func AuthMiddleware(authService *services.AuthService, apiKeyService *services.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if rawKey := c.GetHeader(APIKeyHeader); rawKey != "" {
+			key, user, err := apiKeyService.WithContext(c.Request.Context()).Authenticate(rawKey, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+				c.Abort()
+				return
+			}
+
+			orgID, ok := resolveOrg(c, user.OrgID, user.Role, false)
+			if !ok {
+				return
+			}
+
+			c.Set("user_id", user.ID)
+			c.Set("org_id", orgID)
+			c.Set("role", user.Role)
+			c.Set("auth_method", AuthMethodAPIKey)
+			c.Set("api_key_id", key.ID)
+			// Ключ без областей доступа не разрешает ни одного маршрута с RequireScopes
+			c.Set("scopes", []string(key.Scopes))
+			c.Next()
+			return
+		}
+
 		// Получаем токен из заголовка
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -30,15 +66,64 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Проверяем токен
-		userID, err := utils.ParseToken(parts[1])
+		claims, err := authService.WithContext(c.Request.Context()).ValidateAccessToken(parts[1])
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
 			return
 		}
 
-		// Добавляем ID пользователя в контекст
-		c.Set("user_id", userID)
+		// Токены, выданные до появления организаций, относятся к организации по умолчанию
+		tokenOrgID := claims.OrgID
+		if tokenOrgID == 0 {
+			tokenOrgID = models.DefaultOrgID
+		}
+		orgID, ok := resolveOrg(c, tokenOrgID, claims.Role, claims.Actor != nil)
+		if !ok {
+			return
+		}
+
+		// Добавляем ID пользователя и данные токена в контекст
+		c.Set("user_id", claims.UserID)
+		c.Set("org_id", orgID)
+		c.Set("role", claims.Role)
+		c.Set("auth_method", AuthMethodToken)
+		c.Set("claims", claims)
+		if scopes := claims.Scopes(); len(scopes) > 0 {
+			c.Set("scopes", scopes)
+		}
+
+		if claims.Actor != nil {
+			// Сотрудник работает от имени пользователя, сессия не отображается в списке устройств
+			c.Set("actor_id", claims.Actor.UserID)
+			c.Set("impersonation_id", claims.SessionID)
+		} else if err := authService.WithContext(c.Request.Context()).TouchSession(claims.SessionID, c.ClientIP()); err != nil {
+			// Отмечаем активность сессии для списка устройств пользователя
+			utils.LogError(c.Request.Context(), "TouchSession", err)
+		}
 		c.Next()
 	}
 }
+
+// RequireTokenAuth пропускает только запросы, аутентифицированные access-токеном
+// Используется для управления учетной записью: ключ API не должен выпускать новые ключи или менять 2FA
+func RequireTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_method") != AuthMethodToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this action requires an access token"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetClaims возвращает данные токена, сохраненные AuthMiddleware
+func GetClaims(c *gin.Context) (*utils.Claims, bool) {
+	value, ok := c.Get("claims")
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*utils.Claims)
+	return claims, ok
+}
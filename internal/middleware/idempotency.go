@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"go-crud-api/internal/services"
+	"go-crud-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyHeader - заголовок, в котором клиент передает ключ идемпотентности
+const IdempotencyHeader = "Idempotency-Key"
+
+// replayedHeaders - заголовки ответа, которые сохраняются для повторных запросов
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// responseRecorder сохраняет копию тела ответа
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency обрабатывает заголовок Idempotency-Key для POST запросов
+// Первый ответ сохраняется и возвращается без повторного выполнения
+// для идентичных повторов. Повтор ключа с другим телом запроса получает 422.
+// Должен использоваться после AuthMiddleware: ключи хранятся отдельно для каждого пользователя
+func Idempotency(idempotencyService *services.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+			c.Abort()
+			return
+		}
+
+		// Читаем тело, чтобы посчитать отпечаток запроса, и возвращаем его обработчику
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.Sum256(body)
+		path := c.Request.URL.Path
+
+		record, stored, err := idempotencyService.WithContext(c.Request.Context()).Begin(c.GetUint("user_id"), key, c.Request.Method, path, hex.EncodeToString(hash[:]))
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyMismatch):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		case errors.Is(err, services.ErrIdempotencyKeyInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		case err != nil:
+			utils.LogError(c.Request.Context(), "Idempotency", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process idempotency key"})
+			c.Abort()
+			return
+		}
+
+		// Повтор уже выполненного запроса - отдаем сохраненный ответ
+		if stored != nil {
+			for name, values := range stored.Headers {
+				for _, value := range values {
+					c.Writer.Header().Add(name, value)
+				}
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Status(stored.StatusCode)
+			_, _ = c.Writer.Write(stored.Body)
+			c.Abort()
+			return
+		}
+
+		// Если обработчик упадет с паникой, освобождаем ключ, чтобы он не остался занятым до истечения TTL
+		defer func() {
+			if r := recover(); r != nil {
+				if err := idempotencyService.WithContext(c.Request.Context()).Abort(record); err != nil {
+					utils.LogError(c.Request.Context(), "Idempotency", err)
+				}
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Ошибки сервера не сохраняем, чтобы клиент мог повторить запрос
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := idempotencyService.WithContext(c.Request.Context()).Abort(record); err != nil {
+				utils.LogError(c.Request.Context(), "Idempotency", err)
+			}
+			return
+		}
+
+		headers := http.Header{}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers.Set(name, value)
+			}
+		}
+
+		response := &services.StoredResponse{
+			StatusCode: status,
+			Headers:    headers,
+			Body:       recorder.body.Bytes(),
+		}
+		if err := idempotencyService.WithContext(c.Request.Context()).Complete(record, response); err != nil {
+			utils.LogError(c.Request.Context(), "Idempotency", err)
+		}
+	}
+}
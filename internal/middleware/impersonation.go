@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"go-crud-api/internal/services"
+	"go-crud-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsImpersonating сообщает, что запрос выполняет сотрудник от имени пользователя
+func IsImpersonating(c *gin.Context) bool {
+	_, ok := c.Get("impersonation_id")
+	return ok
+}
+
+// ActorID возвращает ID того, кто на самом деле выполняет запрос:
+// сотрудника при входе от имени пользователя, иначе самого пользователя
+func ActorID(c *gin.Context) uint {
+	if actorID, ok := c.Get("actor_id"); ok {
+		return actorID.(uint)
+	}
+	return c.GetUint("user_id")
+}
+
+// DenyImpersonation запрещает действие при входе от имени пользователя
+// Используется для смены учетных данных, удаления и других необратимых действий
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsImpersonating(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this action is not allowed while impersonating"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// AuditImpersonation сохраняет в журнал каждый запрос, выполненный от имени пользователя
+// Должен использоваться после AuthMiddleware
+func AuditImpersonation(impersonationService *services.ImpersonationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		sessionID := c.GetString("impersonation_id")
+		if sessionID == "" {
+			return
+		}
+
+		status := c.Writer.Status()
+		if err := impersonationService.WithContext(c.Request.Context()).RecordEvent(sessionID, c.Request.Method, c.Request.URL.Path, status); err != nil {
+			utils.LogError(c.Request.Context(), "AuditImpersonation", err)
+		}
+		utils.LogActorOperation(c.Request.Context(), "Impersonation", c.GetUint("user_id"), ActorID(c),
+			fmt.Sprintf("Session %s: %s %s -> %d", sessionID, c.Request.Method, c.Request.URL.Path, status))
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"go-crud-api/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute - значение метки route для запросов, не совпавших ни с одним маршрутом
+const unmatchedRoute = "unmatched"
+
+// Metrics считает HTTP запросы и время их обработки для /metrics
+// Запросы группируются по шаблону маршрута, поэтому /users/1 и /users/2 попадают в один ряд
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-crud-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole пропускает запрос только если роль из токена входит в список
+// Должен использоваться после AuthMiddleware
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasRole(c.GetString("role"), roles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelfOrRole пропускает запрос если ID пользователя в URL совпадает с ID из токена
+// либо если роль из токена входит в список
+// param задает имя параметра маршрута с ID пользователя
+func RequireSelfOrRole(param string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDFromURL, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			c.Abort()
+			return
+		}
+
+		if uint(userIDFromURL) != c.GetUint("user_id") && !hasRole(c.GetString("role"), roles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasRole проверяет, входит ли роль в список разрешенных
+// Администратор платформы имеет права администратора в любой организации
+func hasRole(role string, roles []string) bool {
+	for _, r := range roles {
+		if r == role || (r == models.RoleAdmin && role == models.RoleSuperAdmin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScopes проверяет, что запрос разрешен областями доступа (scopes)
+// Области берутся из claim scope access-токена или из ключа API со списком scopes.
+// Ключ API без областей не проходит проверку, токены без claim scope ограничены только правами пользователя
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if missing := missingScope(c, scopes); missing != "" {
+			abortInsufficientScope(c, missing)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireMethodScope выбирает область доступа по HTTP-методу:
+// read для GET и HEAD, write для остальных методов
+// Удобно для групп маршрутов, где чтение и изменение различаются только методом
+func RequireMethodScope(read, write string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := write
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			scope = read
+		}
+
+		if missing := missingScope(c, []string{scope}); missing != "" {
+			abortInsufficientScope(c, missing)
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortInsufficientScope отвечает 403 с названием недостающей области доступа
+// Заголовок WWW-Authenticate оформлен по RFC 6750
+func abortInsufficientScope(c *gin.Context, missing string) {
+	c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+missing+`"`)
+	c.JSON(http.StatusForbidden, gin.H{"error": "insufficient scope: " + missing + " is required", "missing_scope": missing})
+	c.Abort()
+}
+
+// missingScope возвращает первую область доступа, которой нет у запроса
+// Пустая строка означает, что все области есть или запрос не ограничен областями
+func missingScope(c *gin.Context, required []string) string {
+	value, ok := c.Get("scopes")
+	if !ok {
+		return ""
+	}
+	granted, _ := value.([]string)
+
+	for _, scope := range required {
+		found := false
+		for _, g := range granted {
+			if g == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return scope
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrgIDHeader - заголовок, в котором клиент указывает организацию запроса
+// Он должен совпадать с организацией пользователя; другую организацию может выбрать только superadmin
+const OrgIDHeader = "X-Org-ID"
+
+var errInvalidOrgID = errors.New("invalid " + OrgIDHeader + " header")
+
+// RequestedOrgID возвращает организацию из заголовка X-Org-ID
+// Возвращает 0, если заголовок не передан
+func RequestedOrgID(c *gin.Context) (uint, error) {
+	value := c.GetHeader(OrgIDHeader)
+	if value == "" {
+		return 0, nil
+	}
+	orgID, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || orgID == 0 {
+		return 0, errInvalidOrgID
+	}
+	return uint(orgID), nil
+}
+
+// OrgID возвращает организацию, к данным которой ограничен запрос
+// Значение сохраняется AuthMiddleware
+func OrgID(c *gin.Context) uint {
+	return c.GetUint("org_id")
+}
+
+// resolveOrg определяет организацию запроса по организации пользователя и заголовку X-Org-ID
+// Заголовок должен совпадать с организацией пользователя. Администратор платформы
+// может указать любую организацию, кроме работы от имени пользователя
+func resolveOrg(c *gin.Context, userOrgID uint, role string, impersonating bool) (uint, bool) {
+	requested, err := RequestedOrgID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+		return 0, false
+	}
+	if requested == 0 || requested == userOrgID {
+		return userOrgID, true
+	}
+	if role == models.RoleSuperAdmin && !impersonating {
+		return requested, true
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "access to this organization is denied"})
+	c.Abort()
+	return 0, false
+}
+
+// RequireTenantUser пропускает запрос только если пользователь из URL принадлежит организации запроса
+// Нужен для маршрутов, сервисы которых работают с пользователями по ID без учета организации
+// (сессии, ключи API). param задает имя параметра маршрута с ID пользователя
+func RequireTenantUser(param string, userService *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			c.Abort()
+			return
+		}
+
+		if _, err := userService.WithContext(c.Request.Context()).ForOrg(OrgID(c)).GetByID(uint(userID)); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go-crud-api/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TraceIDHeader - заголовок ответа с ID трассировки запроса
+const TraceIDHeader = "X-Trace-ID"
+
+// errorBodyRecorder задерживает JSON тело ответов с ошибкой, чтобы добавить в него trace_id
+// Остальные ответы передаются клиенту без изменений
+type errorBodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// buffering сообщает, что тело текущего ответа нужно задержать
+func (w *errorBodyRecorder) buffering() bool {
+	return w.Status() >= http.StatusBadRequest &&
+		strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
+
+func (w *errorBodyRecorder) Write(data []byte) (int, error) {
+	if w.buffering() {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorBodyRecorder) WriteString(s string) (int, error) {
+	if w.buffering() {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorBodyRecorder) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Tracing возвращает ID трассировки в заголовке X-Trace-ID и в поле trace_id ответов с ошибкой
+// По этому ID ошибку клиента можно найти в трассировке и в логах.
+// Должен использоваться после otelgin, который создает span запроса
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		traceID := tracing.TraceID(c.Request.Context())
+		if traceID == "" {
+			c.Next()
+			return
+		}
+
+		c.Header(TraceIDHeader, traceID)
+		recorder := &errorBodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		c.Writer = recorder.ResponseWriter
+		if recorder.body.Len() == 0 {
+			return
+		}
+
+		body := recorder.body.Bytes()
+		var fields map[string]interface{}
+		if err := json.Unmarshal(body, &fields); err == nil {
+			if _, ok := fields["trace_id"]; !ok {
+				fields["trace_id"] = traceID
+				if withTraceID, err := json.Marshal(fields); err == nil {
+					body = withTraceID
+				}
+			}
+		}
+		c.Writer.Write(body)
+	}
+}
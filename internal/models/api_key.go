@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix - префикс, по которому ключи API легко отличить от других секретов
+const APIKeyPrefix = "gca_"
+
+// APIKey представляет персональный ключ API для доступа без пароля
+// Хранится только хеш ключа, сам ключ показывается пользователю один раз.
+// Prefix - открытая часть ключа, по которой ключ находится в БД
+type APIKey struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	UserID     uint           `json:"user_id" gorm:"not null"`
+	Name       string         `json:"name" gorm:"not null"`
+	Prefix     string         `json:"prefix" gorm:"not null;unique"`
+	KeyHash    string         `json:"-" gorm:"not null"`
+	Scopes     pq.StringArray `json:"scopes" gorm:"type:text[];not null"`
+	ExpiresAt  *time.Time     `json:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	LastUsedIP string         `json:"last_used_ip,omitempty" gorm:"column:last_used_ip"`
+	RevokedAt  *time.Time     `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// CreateAPIKeyRequest представляет данные для создания ключа API
+// Scopes и ExpiresAt опциональны: ключ без областей доступа не дает доступа ни к одному маршруту,
+// без ExpiresAt ключ бессрочный
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"omitempty,dive,oneof=users:read users:write orders:read orders:write products:read products:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyCreatedResponse возвращается при создании ключа API
+// Key показывается только в этом ответе
+type APIKeyCreatedResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
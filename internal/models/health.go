@@ -0,0 +1,14 @@
+package models
+
+// Состояния проверок готовности
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthResponse представляет ответ /healthz и /readyz
+// Checks содержит результат каждой проверенной зависимости: "ok" или текст ошибки
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
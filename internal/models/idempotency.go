@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey представляет сохраненный результат запроса с заголовком Idempotency-Key
+// Повторный запрос с тем же ключом получает сохраненный ответ вместо повторного выполнения
+type IdempotencyKey struct {
+	ID              uint   `gorm:"primaryKey"`
+	UserID          uint   `gorm:"not null"`
+	Key             string `gorm:"not null"`
+	Method          string `gorm:"not null"`
+	Path            string `gorm:"not null"`
+	RequestHash     string `gorm:"not null"` // Отпечаток метода, пути и тела запроса
+	StatusCode      *int   // Пусто, пока первый запрос еще выполняется
+	ResponseHeaders string `gorm:"type:jsonb;not null;default:'{}'"`
+	ResponseBody    []byte
+	CreatedAt       time.Time
+	ExpiresAt       time.Time `gorm:"not null"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// ImpersonationSession представляет вход сотрудника от имени пользователя
+// Запись создается при выдаче токена и остается в журнале после его истечения
+type ImpersonationSession struct {
+	ID        string     `json:"id" gorm:"primaryKey"` // Совпадает с sid в токене
+	OrgID     uint       `json:"org_id" gorm:"not null"`
+	ActorID   uint       `json:"actor_id" gorm:"not null"`
+	UserID    uint       `json:"user_id" gorm:"not null"`
+	Reason    string     `json:"reason" gorm:"not null"`
+	IP        string     `json:"ip" gorm:"column:ip"`
+	UserAgent string     `json:"user_agent"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ImpersonationEvent представляет запрос, выполненный от имени пользователя
+type ImpersonationEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	SessionID string    `json:"session_id" gorm:"not null"`
+	Method    string    `json:"method" gorm:"not null"`
+	Path      string    `json:"path" gorm:"not null"`
+	Status    int       `json:"status" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ImpersonateRequest представляет запрос на вход от имени пользователя
+// Причина обязательна и сохраняется в журнале
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// ImpersonationResponse содержит токен для работы от имени пользователя
+// Refresh-токен не выдается: по истечении срока нужно начать заново
+type ImpersonationResponse struct {
+	Token           string `json:"token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"` // Время жизни токена в секундах
+	ImpersonationID string `json:"impersonation_id"`
+	UserID          uint   `json:"user_id"`
+}
+
+// ImpersonationFilter задает фильтры журнала входов от имени пользователей
+type ImpersonationFilter struct {
+	ActorID uint `form:"actor_id"`
+	UserID  uint `form:"user_id"`
+	Limit   int  `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// ImpersonationDetails содержит сессию из журнала вместе с выполненными запросами
+type ImpersonationDetails struct {
+	ImpersonationSession
+	Events []ImpersonationEvent `json:"events"`
+}
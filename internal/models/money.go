@@ -0,0 +1,172 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency используется, если валюта товара не указана
+const DefaultCurrency = "RUB"
+
+// Money представляет денежную сумму в минимальных единицах валюты (копейках, центах, иенах)
+// Хранится как целое число, поэтому суммы не накапливают ошибку округления.
+// Сама сумма не знает своей валюты: в JSON ее кодирует модель-владелец вместе с валютой,
+// например "12.34" для RUB, "1234" для JPY и "1.234" для KWD
+type Money int64
+
+var (
+	// ErrInvalidMoney возвращается при разборе некорректной суммы
+	ErrInvalidMoney = errors.New("invalid money amount")
+	// ErrMoneyOverflow возвращается, если сумма не помещается в int64
+	ErrMoneyOverflow = errors.New("money amount out of range")
+)
+
+// Mul умножает сумму на количество
+// Возвращает ErrMoneyOverflow при переполнении
+func (m Money) Mul(quantity int) (Money, error) {
+	q := Money(quantity)
+	if m == 0 || q == 0 {
+		return 0, nil
+	}
+	result := m * q
+	if result/q != m || (m == -1 && q == math.MinInt64) || (q == -1 && m == math.MinInt64) {
+		return 0, fmt.Errorf("%w: %d * %d", ErrMoneyOverflow, m, quantity)
+	}
+	return result, nil
+}
+
+// Add складывает суммы
+// Возвращает ErrMoneyOverflow при переполнении
+func (m Money) Add(other Money) (Money, error) {
+	result := m + other
+	if (other > 0 && result < m) || (other < 0 && result > m) {
+		return 0, fmt.Errorf("%w: %d + %d", ErrMoneyOverflow, m, other)
+	}
+	return result, nil
+}
+
+// Format возвращает сумму в виде десятичной строки с количеством знаков после запятой,
+// принятым в валюте currency
+func (m Money) Format(currency string) string {
+	exponent := currencyExponent(currency)
+
+	sign := ""
+	// Модуль считается в uint64: у math.MinInt64 нет положительной пары в int64
+	value := uint64(m)
+	if m < 0 {
+		sign = "-"
+		value = uint64(-m)
+	}
+	if exponent == 0 {
+		return sign + strconv.FormatUint(value, 10)
+	}
+
+	scale := uint64(math.Pow10(exponent))
+	return fmt.Sprintf("%s%d.%0*d", sign, value/scale, exponent, value%scale)
+}
+
+// Float64 возвращает сумму в основных единицах валюты (рублях, долларах)
+// Только для метрик и отчетов: расчеты выполняются в целых минимальных единицах
+func (m Money) Float64(currency string) float64 {
+	return float64(m) / math.Pow10(currencyExponent(currency))
+}
+
+// ParseMoney разбирает десятичную строку в сумму в валюте currency
+// Лишние знаки после запятой не округляются, а считаются ошибкой:
+// "12.345" допустимо для KWD, но не для RUB, а "12.5" - не для JPY
+func ParseMoney(text, currency string) (Money, error) {
+	exponent, err := CurrencyExponent(currency)
+	if err != nil {
+		return 0, err
+	}
+
+	digits := text
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign = "-"
+		digits = digits[1:]
+	}
+
+	whole, fraction, hasFraction := strings.Cut(digits, ".")
+	if !isDigits(whole) || (hasFraction && !isDigits(fraction)) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, text)
+	}
+	if len(fraction) > exponent {
+		return 0, fmt.Errorf("%w: at most %d decimal places allowed for %s", ErrInvalidMoney, exponent, currency)
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	units, err := strconv.ParseInt(sign+whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrMoneyOverflow, text)
+	}
+	return Money(units), nil
+}
+
+// isDigits проверяет, что строка непустая и состоит только из цифр
+func isDigits(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Value сохраняет сумму в БД как целое число минимальных единиц
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+// Scan читает сумму из БД
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case int64:
+		*m = Money(v)
+	case []byte:
+		units, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return err
+		}
+		*m = Money(units)
+	case nil:
+		*m = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Money", value)
+	}
+	return nil
+}
+
+// Amount представляет денежную сумму из запроса в десятичной записи
+// В Money переводится только вместе с валютой, потому что от нее зависит
+// количество знаков после запятой
+type Amount string
+
+// UnmarshalJSON принимает сумму строкой ("12.34") или числом (12.34)
+// Число сохраняется как текст без преобразования во float64
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "null" {
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	*a = Amount(text)
+	return nil
+}
+
+// Money разбирает сумму в валюте currency
+func (a Amount) Money(currency string) (Money, error) {
+	return ParseMoney(string(a), currency)
+}
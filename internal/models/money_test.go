@@ -0,0 +1,285 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		currency string
+		want     Money
+		wantErr  error
+	}{
+		{"rub whole", "49", "RUB", 4900, nil},
+		{"rub cents", "49.90", "RUB", 4990, nil},
+		{"rub one decimal", "49.9", "RUB", 4990, nil},
+		{"rub zero", "0", "RUB", 0, nil},
+		{"rub negative", "-12.34", "RUB", -1234, nil},
+		{"jpy", "1500", "JPY", 1500, nil},
+		{"jpy negative", "-1500", "JPY", -1500, nil},
+		{"kwd", "1.234", "KWD", 1234, nil},
+		{"kwd short fraction", "1.2", "KWD", 1200, nil},
+		{"bhd", "0.005", "BHD", 5, nil},
+		{"clf", "1.2345", "CLF", 12345, nil},
+		{"max", "92233720368547758.07", "USD", math.MaxInt64, nil},
+		{"min", "-92233720368547758.08", "USD", math.MinInt64, nil},
+
+		// Лишние знаки не округляются, а отклоняются
+		{"rub excess precision", "49.999", "RUB", 0, ErrInvalidMoney},
+		{"rub half cent", "0.005", "RUB", 0, ErrInvalidMoney},
+		{"jpy fraction", "1500.5", "JPY", 0, ErrInvalidMoney},
+		{"jpy zero fraction", "1500.00", "JPY", 0, ErrInvalidMoney},
+		{"kwd excess precision", "1.2345", "KWD", 0, ErrInvalidMoney},
+
+		{"overflow", "92233720368547758.08", "USD", 0, ErrMoneyOverflow},
+		{"negative overflow", "-92233720368547758.09", "USD", 0, ErrMoneyOverflow},
+		{"jpy overflow", "9223372036854775808", "JPY", 0, ErrMoneyOverflow},
+
+		{"empty", "", "RUB", 0, ErrInvalidMoney},
+		{"minus only", "-", "RUB", 0, ErrInvalidMoney},
+		{"no whole part", ".50", "RUB", 0, ErrInvalidMoney},
+		{"trailing dot", "12.", "RUB", 0, ErrInvalidMoney},
+		{"plus sign", "+12", "RUB", 0, ErrInvalidMoney},
+		{"double minus", "--12", "RUB", 0, ErrInvalidMoney},
+		{"minus in fraction", "12.-5", "RUB", 0, ErrInvalidMoney},
+		{"exponent", "1e3", "RUB", 0, ErrInvalidMoney},
+		{"comma", "12,50", "RUB", 0, ErrInvalidMoney},
+		{"spaces", " 12", "RUB", 0, ErrInvalidMoney},
+
+		{"unknown currency", "12", "ABC", 0, ErrUnknownCurrency},
+		{"metal", "12", "XAU", 0, ErrUnknownCurrency},
+		{"lowercase currency", "12", "rub", 0, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMoney(tt.text, tt.currency)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseMoney(%q, %s): expected %v, got %v (%d)", tt.text, tt.currency, tt.wantErr, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q, %s): %v", tt.text, tt.currency, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseMoney(%q, %s) = %d, want %d", tt.text, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		money    Money
+		currency string
+		want     string
+	}{
+		{4990, "RUB", "49.90"},
+		{5, "RUB", "0.05"},
+		{0, "RUB", "0.00"},
+		{-1234, "RUB", "-12.34"},
+		{-5, "USD", "-0.05"},
+		{1500, "JPY", "1500"},
+		{-1500, "JPY", "-1500"},
+		{0, "JPY", "0"},
+		{1234, "KWD", "1.234"},
+		{5, "BHD", "0.005"},
+		{-5, "BHD", "-0.005"},
+		{12345, "CLF", "1.2345"},
+		{math.MaxInt64, "USD", "92233720368547758.07"},
+		{math.MinInt64, "USD", "-92233720368547758.08"},
+		{math.MinInt64, "JPY", "-9223372036854775808"},
+		// Валюта вне справочника (старые записи) форматируется с двумя знаками
+		{4990, "ABC", "49.90"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.Format(tt.currency); got != tt.want {
+			t.Errorf("Money(%d).Format(%s) = %q, want %q", tt.money, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyFormatParseRoundTrip(t *testing.T) {
+	values := []Money{0, 1, -1, 99, 100, 101, 123456789, -987654321, math.MaxInt64, math.MinInt64}
+	for currency := range currencyExponents {
+		for _, value := range values {
+			text := value.Format(currency)
+			parsed, err := ParseMoney(text, currency)
+			if err != nil {
+				t.Fatalf("%s: ParseMoney(%q): %v", currency, text, err)
+			}
+			if parsed != value {
+				t.Fatalf("%s: round trip of %d gave %d via %q", currency, value, parsed, text)
+			}
+		}
+	}
+}
+
+func TestMoneyMul(t *testing.T) {
+	tests := []struct {
+		name     string
+		money    Money
+		quantity int
+		want     Money
+		overflow bool
+	}{
+		{"simple", 4990, 3, 14970, false},
+		{"zero quantity", 4990, 0, 0, false},
+		{"zero money", 0, math.MaxInt, 0, false},
+		{"negative", -4990, 2, -9980, false},
+		{"max", math.MaxInt64, 1, math.MaxInt64, false},
+		{"overflow", math.MaxInt64/2 + 1, 2, 0, true},
+		{"large quantity", 4990, math.MaxInt, 0, true},
+		{"negative overflow", math.MinInt64, 2, 0, true},
+		{"min by minus one", math.MinInt64, -1, 0, true},
+		{"minus one by min", -1, math.MinInt, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.money.Mul(tt.quantity)
+			if tt.overflow {
+				if !errors.Is(err, ErrMoneyOverflow) {
+					t.Fatalf("expected ErrMoneyOverflow, got %d, %v", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Mul = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	tests := []struct {
+		a, b     Money
+		want     Money
+		overflow bool
+	}{
+		{4990, 10, 5000, false},
+		{4990, -5000, -10, false},
+		{math.MaxInt64, 0, math.MaxInt64, false},
+		{math.MaxInt64, -1, math.MaxInt64 - 1, false},
+		{math.MinInt64, math.MaxInt64, -1, false},
+		{math.MaxInt64, 1, 0, true},
+		{math.MinInt64, -1, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+		if tt.overflow {
+			if !errors.Is(err, ErrMoneyOverflow) {
+				t.Errorf("%d + %d: expected ErrMoneyOverflow, got %d, %v", tt.a, tt.b, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%d + %d = %d, %v, want %d", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyFloat64(t *testing.T) {
+	tests := []struct {
+		money    Money
+		currency string
+		want     float64
+	}{
+		{4990, "RUB", 49.9},
+		{1500, "JPY", 1500},
+		{1234, "KWD", 1.234},
+	}
+	for _, tt := range tests {
+		if got := tt.money.Float64(tt.currency); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Money(%d).Float64(%s) = %v, want %v", tt.money, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestAmountUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json     string
+		currency string
+		want     Money
+		wantErr  error
+	}{
+		{`"49.90"`, "RUB", 4990, nil},
+		{`49.90`, "RUB", 4990, nil},
+		{`1500`, "JPY", 1500, nil},
+		{`1.234`, "KWD", 1234, nil},
+		// Число не проходит через float64: 0.1 + 0.2 не превращается в 0.30000000000000004
+		{`0.3`, "USD", 30, nil},
+		{`49.999`, "RUB", 0, ErrInvalidMoney},
+		{`1e3`, "RUB", 0, ErrInvalidMoney},
+		{`true`, "RUB", 0, ErrInvalidMoney},
+	}
+	for _, tt := range tests {
+		var req struct {
+			Price Amount `json:"price"`
+		}
+		if err := json.Unmarshal([]byte(`{"price":`+tt.json+`}`), &req); err != nil {
+			t.Fatalf("unmarshal %s: %v", tt.json, err)
+		}
+		got, err := req.Price.Money(tt.currency)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s in %s: expected %v, got %d, %v", tt.json, tt.currency, tt.wantErr, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s in %s = %d, %v, want %d", tt.json, tt.currency, got, err, tt.want)
+		}
+	}
+}
+
+func TestMarshalJSONUsesCurrencyExponent(t *testing.T) {
+	product, err := json.Marshal(Product{ID: 1, Name: "Tea", Price: 1500, Currency: "JPY"})
+	if err != nil {
+		t.Fatalf("marshal product: %v", err)
+	}
+	var gotProduct map[string]interface{}
+	if err := json.Unmarshal(product, &gotProduct); err != nil {
+		t.Fatalf("unmarshal product: %v", err)
+	}
+	if gotProduct["price"] != "1500" || gotProduct["name"] != "Tea" {
+		t.Fatalf("unexpected product JSON: %s", product)
+	}
+
+	order, err := json.Marshal(&Order{
+		ID:       1,
+		Currency: "KWD",
+		Subtotal: 2468,
+		Total:    2468,
+		Items:    []OrderItem{{ID: 1, Product: "Tea", Quantity: 2, UnitPrice: 1234, LineTotal: 2468}},
+	})
+	if err != nil {
+		t.Fatalf("marshal order: %v", err)
+	}
+	var gotOrder struct {
+		Subtotal string `json:"subtotal"`
+		Total    string `json:"total"`
+		Currency string `json:"currency"`
+		Items    []struct {
+			Product   string `json:"product"`
+			Quantity  int    `json:"quantity"`
+			UnitPrice string `json:"unit_price"`
+			LineTotal string `json:"line_total"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(order, &gotOrder); err != nil {
+		t.Fatalf("unmarshal order: %v", err)
+	}
+	if gotOrder.Subtotal != "2.468" || gotOrder.Total != "2.468" || gotOrder.Currency != "KWD" {
+		t.Fatalf("unexpected order JSON: %s", order)
+	}
+	if len(gotOrder.Items) != 1 || gotOrder.Items[0].UnitPrice != "1.234" || gotOrder.Items[0].LineTotal != "2.468" ||
+		gotOrder.Items[0].Product != "Tea" || gotOrder.Items[0].Quantity != 2 {
+		t.Fatalf("unexpected order items JSON: %s", order)
+	}
+}
@@ -1,31 +1,142 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Статусы заказа
+const (
+	OrderStatusPending   = "pending"   // Создан, ожидает оплаты
+	OrderStatusPaid      = "paid"      // Оплачен
+	OrderStatusShipped   = "shipped"   // Передан в доставку
+	OrderStatusDelivered = "delivered" // Доставлен
+	OrderStatusCancelled = "cancelled" // Отменен
+	OrderStatusRefunded  = "refunded"  // Деньги возвращены
+)
+
 // Order представляет модель заказа в системе
-// Связан с пользователем через UserID
+// Связан с пользователем через UserID, состоит из одной или нескольких позиций
 type Order struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
+	OrgID     uint           `json:"org_id" gorm:"not null"` // Организация, заполняется из организации запроса
 	UserID    uint           `json:"user_id" gorm:"not null"`
-	Product   string         `json:"product" gorm:"not null"`
-	Quantity  int            `json:"quantity" gorm:"not null"`
-	Price     float64        `json:"price" gorm:"not null"`
+	Status    string         `json:"status" gorm:"not null;default:pending"`
+	Subtotal  Money          `json:"subtotal" gorm:"not null"` // Сумма позиций, считается на сервере
+	Total     Money          `json:"total" gorm:"not null"`    // Итоговая сумма заказа
+	Currency  string         `json:"currency" gorm:"not null"` // Валюта всех позиций заказа
+	Items     []OrderItem    `json:"items" gorm:"foreignKey:OrderID"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`             // Мягкое удаление
 	User      *User          `json:"-" gorm:"foreignKey:UserID"` // Скрываем данные пользователя из JSON
 }
 
+// OrderItem представляет позицию заказа
+// Название и цена товара копируются из каталога на момент покупки
+type OrderItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	OrderID   uint      `json:"order_id" gorm:"not null"`
+	ProductID *uint     `json:"product_id"` // Пусто для позиций, созданных до появления каталога
+	Product   string    `json:"product" gorm:"not null"`
+	Quantity  int       `json:"quantity" gorm:"not null"`
+	UnitPrice Money     `json:"unit_price" gorm:"not null"`
+	LineTotal Money     `json:"line_total" gorm:"not null"` // UnitPrice * Quantity
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// orderItem - OrderItem без собственных методов, используется при кодировании заказа в JSON
+type orderItem OrderItem
+
+// orderItemJSON - позиция заказа с суммами, отформатированными в валюте заказа
+type orderItemJSON struct {
+	orderItem
+	UnitPrice string `json:"unit_price"`
+	LineTotal string `json:"line_total"`
+}
+
+// MarshalJSON кодирует суммы заказа и его позиций строками с количеством знаков
+// после запятой, принятым в валюте заказа
+func (o Order) MarshalJSON() ([]byte, error) {
+	type order Order
+
+	var items []orderItemJSON
+	if o.Items != nil {
+		items = make([]orderItemJSON, len(o.Items))
+		for i, item := range o.Items {
+			items[i] = orderItemJSON{
+				orderItem: orderItem(item),
+				UnitPrice: item.UnitPrice.Format(o.Currency),
+				LineTotal: item.LineTotal.Format(o.Currency),
+			}
+		}
+	}
+
+	return json.Marshal(struct {
+		order
+		Subtotal string          `json:"subtotal"`
+		Total    string          `json:"total"`
+		Items    []orderItemJSON `json:"items"`
+	}{order(o), o.Subtotal.Format(o.Currency), o.Total.Format(o.Currency), items})
+}
+
+// OrderItemRequest представляет позицию в запросе на создание или изменение заказа
+// Цена не передается клиентом - она берется из каталога
+type OrderItemRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,gt=0"`
+}
+
 // CreateOrderRequest представляет данные для создания нового заказа
 // Используется при создании заказа
 type CreateOrderRequest struct {
-	Product  string  `json:"product" binding:"required"`
-	Quantity int     `json:"quantity" binding:"required,gt=0"`
-	Price    float64 `json:"price" binding:"required,gt=0"`
+	Items []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// UpdateOrderRequest представляет данные для изменения заказа
+// Позиции заказа заменяются целиком
+type UpdateOrderRequest struct {
+	Items []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// NewOrderItems преобразует позиции из запроса в позиции заказа
+// Название, цена и суммы позиций не заполняются - их проставляет OrderService
+func NewOrderItems(items []OrderItemRequest) []OrderItem {
+	result := make([]OrderItem, len(items))
+	for i, item := range items {
+		productID := item.ProductID
+		result[i] = OrderItem{
+			ProductID: &productID,
+			Quantity:  item.Quantity,
+		}
+	}
+	return result
+}
+
+// OrderStatusHistory представляет запись о смене статуса заказа
+// Фиксирует, кто и когда перевел заказ из одного статуса в другой
+type OrderStatusHistory struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	OrderID    uint      `json:"order_id" gorm:"not null"`
+	FromStatus *string   `json:"from_status"` // Пусто для начального статуса
+	ToStatus   string    `json:"to_status" gorm:"not null"`
+	ChangedBy  uint      `json:"changed_by"`
+	Comment    string    `json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName задает имя таблицы истории статусов
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+// OrderTransitionRequest представляет данные для смены статуса заказа
+// Комментарий опционален и сохраняется в истории
+type OrderTransitionRequest struct {
+	Comment string `json:"comment"`
 }
 
 func (o *Order) BeforeCreate(tx *gorm.DB) error {
@@ -38,3 +149,43 @@ func (o *Order) BeforeUpdate(tx *gorm.DB) error {
 	o.UpdatedAt = time.Now()
 	return nil
 }
+
+// OrderListParams представляет параметры списка заказов из query-строки
+// Пример: ?status=paid&min_price=10.00&created_from=2024-01-01T00:00:00Z&sort=-created_at,price
+type OrderListParams struct {
+	Page        int       `form:"page" binding:"min=0"`
+	Limit       int       `form:"limit" binding:"min=0,max=100"`
+	UserID      uint      `form:"user_id"` // Учитывается только в списке всех заказов
+	Status      string    `form:"status" binding:"omitempty,oneof=pending paid shipped delivered cancelled refunded"`
+	ProductID   uint      `form:"product_id"`
+	Product     string    `form:"product"`                              // Поиск по названию товара в позициях
+	Currency    string    `form:"currency" binding:"omitempty,iso4217"` // По умолчанию DefaultCurrency, если задан диапазон суммы
+	MinPrice    string    `form:"min_price"`                            // Диапазон итоговой суммы заказа в валюте currency
+	MaxPrice    string    `form:"max_price"`
+	CreatedFrom time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Sort        string    `form:"sort"`   // Поля через запятую, "-" перед полем - по убыванию
+	Cursor      string    `form:"cursor"` // Курсор keyset-пагинации, несовместим с sort
+}
+
+// SortField представляет поле сортировки
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// OrderFilter представляет проверенные условия выборки заказов для репозитория
+type OrderFilter struct {
+	UserID      uint
+	Status      string
+	ProductID   uint
+	Product     string
+	Currency    string
+	MinTotal    *Money
+	MaxTotal    *Money
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Sort        []SortField
+	Page        int
+	Limit       int
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultOrgID - организация, в которую попадают пользователи, если организация не указана
+// Создается миграцией, к ней отнесены все данные, созданные до появления организаций
+const DefaultOrgID uint = 1
+
+// Organization представляет организацию (бренд), которой принадлежат пользователи и заказы
+// Данные разных организаций изолированы друг от друга
+type Organization struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	Slug      string    `json:"slug" gorm:"unique;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateOrganizationRequest представляет данные для создания организации
+// Доступно только администраторам платформы
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+	Slug string `json:"slug" binding:"required,max=50"`
+}
@@ -1,11 +1,13 @@
 package models
 
 // PaginationParams представляет параметры пагинации для списков
+// Если передан параметр cursor (в том числе пустой), используется keyset-пагинация
 type PaginationParams struct {
-	Page   int `form:"page" binding:"min=1"`
-	Limit  int `form:"limit" binding:"min=1,max=100"`
-	MinAge int `form:"min_age" binding:"min=0"`
-	MaxAge int `form:"max_age" binding:"min=0"`
+	Page   int    `form:"page" binding:"min=0"`
+	Limit  int    `form:"limit" binding:"min=0,max=100"`
+	MinAge int    `form:"min_age" binding:"min=0"`
+	MaxAge int    `form:"max_age" binding:"min=0"`
+	Cursor string `form:"cursor"`
 }
 
 // PaginatedResponse представляет ответ с пагинацией
@@ -16,3 +18,34 @@ type PaginatedResponse struct {
 	TotalPages int         `json:"total_pages"`
 	Users      interface{} `json:"users"`
 }
+
+// Page представляет универсальный ответ с пагинацией
+type Page[T any] struct {
+	Items      []T   `json:"items"`
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// NewPage формирует страницу ответа и считает общее количество страниц
+func NewPage[T any](items []T, page, limit int, total int64) *Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return &Page[T]{
+		Items:      items,
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: (int(total) + limit - 1) / limit,
+	}
+}
+
+// CursorPage представляет ответ с keyset-пагинацией
+// NextCursor пустой, если следующей страницы нет
+type CursorPage[T any] struct {
+	Items      []T    `json:"items"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
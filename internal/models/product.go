@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Product представляет товар из каталога
+// Заказы ссылаются на товар по ID и фиксируют его цену на момент покупки
+type Product struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"not null"`
+	Description string         `json:"description"`
+	Price       Money          `json:"price" gorm:"not null"`
+	Currency    string         `json:"currency" gorm:"not null"` // Код валюты ISO 4217
+	Stock       int            `json:"stock" gorm:"not null"`    // Доступный остаток на складе
+	Active      bool           `json:"active" gorm:"not null"`   // Неактивный товар нельзя заказать
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"` // Мягкое удаление
+}
+
+// MarshalJSON кодирует цену строкой с количеством знаков после запятой, принятым в валюте товара
+func (p Product) MarshalJSON() ([]byte, error) {
+	type product Product
+	return json.Marshal(struct {
+		product
+		Price string `json:"price"`
+	}{product(p), p.Price.Format(p.Currency)})
+}
+
+// CreateProductRequest представляет данные для создания товара
+type CreateProductRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Price       Amount `json:"price" binding:"required"`             // Разбирается в валюте товара
+	Currency    string `json:"currency" binding:"omitempty,iso4217"` // По умолчанию DefaultCurrency
+	Stock       int    `json:"stock" binding:"gte=0"`
+	Active      *bool  `json:"active"` // По умолчанию товар активен
+}
+
+// UpdateProductRequest представляет данные для обновления товара
+// Все поля опциональны
+type UpdateProductRequest struct {
+	Name        string  `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Price       *Amount `json:"price,omitempty"`
+	Currency    string  `json:"currency,omitempty" binding:"omitempty,iso4217"` // При смене валюты цену нужно передать заново
+	Stock       *int    `json:"stock,omitempty" binding:"omitempty,gte=0"`
+	Active      *bool   `json:"active,omitempty"`
+}
+
+// ProductListParams представляет параметры поиска товаров
+type ProductListParams struct {
+	Page  int    `form:"page" binding:"min=0"`
+	Limit int    `form:"limit" binding:"min=0,max=100"`
+	Query string `form:"q"` // Поиск по названию и описанию
+}
+
+// LowStockParams представляет параметры отчета о заканчивающихся товарах
+type LowStockParams struct {
+	Threshold int `form:"threshold" binding:"min=0"` // Товары с остатком не больше порога
+}
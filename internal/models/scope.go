@@ -0,0 +1,35 @@
+package models
+
+// Области доступа (scopes) для токенов и ключей API
+// Ключ без областей доступа не дает доступа ни к одному маршруту, проверяющему области
+const (
+	ScopeUsersRead     = "users:read"
+	ScopeUsersWrite    = "users:write"
+	ScopeOrdersRead    = "orders:read"
+	ScopeOrdersWrite   = "orders:write"
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeAccountRead   = "account:read"  // Просмотр сессий и ключей API
+	ScopeAccountWrite  = "account:write" // Управление 2FA, сессиями и ключами API
+	ScopeAdminRead     = "admin:read"    // Журнал входов от имени пользователей
+	ScopeAdminWrite    = "admin:write"   // Вход от имени пользователя
+)
+
+// AllScopes - области доступа токена, выданного при входе
+// Права внутри областей по-прежнему ограничены ролью пользователя
+var AllScopes = []string{
+	ScopeUsersRead, ScopeUsersWrite,
+	ScopeOrdersRead, ScopeOrdersWrite,
+	ScopeProductsRead, ScopeProductsWrite,
+	ScopeAccountRead, ScopeAccountWrite,
+	ScopeAdminRead, ScopeAdminWrite,
+}
+
+// ImpersonationScopes - области доступа токена входа от имени пользователя
+// Управление учетной записью и администрирование в них не входят
+var ImpersonationScopes = []string{
+	ScopeUsersRead, ScopeUsersWrite,
+	ScopeOrdersRead, ScopeOrdersWrite,
+	ScopeProductsRead, ScopeProductsWrite,
+	ScopeAccountRead,
+}
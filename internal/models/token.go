@@ -0,0 +1,209 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Session представляет сессию пользователя (один вход в систему)
+// Объединяет семейство refresh-токенов, выданных по цепочке ротаций
+type Session struct {
+	ID           string         `json:"id" gorm:"primaryKey"`
+	UserID       uint           `json:"user_id" gorm:"not null"`
+	Device       string         `json:"device"` // Краткое описание устройства, полученное из User-Agent
+	UserAgent    string         `json:"user_agent"`
+	IP           string         `json:"ip" gorm:"column:ip"`
+	Scopes       pq.StringArray `json:"scopes,omitempty" gorm:"type:text[]"` // Пусто - все области доступа
+	CreatedAt    time.Time      `json:"created_at"`
+	LastSeenAt   time.Time      `json:"last_seen_at"`
+	RevokedAt    *time.Time     `json:"revoked_at,omitempty"`
+	RevokeReason string         `json:"-"`
+}
+
+// ClientInfo описывает клиента, с которого выполняется вход или запрос
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// SessionResponse представляет активную сессию пользователя для ответа API
+// Current отмечает сессию, из которой выполнен запрос
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Scopes     []string  `json:"scopes,omitempty"` // Только для сессий, полученных обменом токена
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// RefreshToken представляет refresh-токен, сохраненный в БД
+// Хранится только хеш токена, сам токен выдается клиенту один раз
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	SessionID string     `gorm:"not null"`
+	UserID    uint       `gorm:"not null"`
+	TokenHash string     `gorm:"not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // Время ротации токена
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken представляет отозванный access-токен
+// Запись нужна только до истечения срока действия токена
+type RevokedToken struct {
+	JTI       string    `gorm:"column:jti;primaryKey"`
+	UserID    uint      `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+// TokenResponse представляет пару токенов, выдаваемую при входе и обновлении
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // Время жизни access-токена в секундах
+	Scope        string `json:"scope"`      // Области доступа через пробел
+}
+
+// RefreshTokenRequest представляет данные для обновления пары токенов
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenExchangeRequest представляет запрос на обмен токена на токен с меньшими правами
+// Запрошенные области должны входить в области текущего токена
+type TokenExchangeRequest struct {
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=users:read users:write orders:read orders:write products:read products:write account:read account:write admin:read admin:write"`
+}
+
+// PasswordResetToken представляет одноразовый токен сброса пароля
+// Хранится только хеш токена, сам токен отправляется пользователю на почту
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null"`
+	TokenHash string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// ForgotPasswordRequest представляет запрос на отправку письма для сброса пароля
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest представляет данные для установки нового пароля
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// EmailVerificationToken представляет одноразовый токен подтверждения email
+// Токен действителен только для адреса, на который был отправлен
+type EmailVerificationToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null"`
+	Email     string    `gorm:"not null"`
+	TokenHash string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// VerifyEmailRequest представляет данные для подтверждения email
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest представляет запрос на повторную отправку письма с подтверждением
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RecoveryCode представляет одноразовый код восстановления доступа при включенной 2FA
+// Хранится только хеш кода, сами коды показываются пользователю один раз
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null"`
+	CodeHash  string `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// TwoFactorChallenge представляет незавершенный вход пользователя с включенной 2FA
+// Токен выдается после проверки пароля и обменивается на пару токенов вместе с кодом
+type TwoFactorChallenge struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null"`
+	TokenHash string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Attempts  int       `gorm:"not null;default:0"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// TwoFactorChallengeResponse возвращается при входе, если у пользователя включена 2FA
+type TwoFactorChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"` // Время жизни mfa_token в секундах
+}
+
+// TwoFactorLoginRequest представляет второй шаг входа: промежуточный токен и код
+// Вместо кода из приложения можно передать код восстановления
+type TwoFactorLoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorEnrollResponse содержит секрет и otpauth URI для подключения 2FA
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorCodeRequest представляет код из приложения-аутентификатора
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse содержит новые коды восстановления
+// Коды показываются только один раз
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ExternalIdentity связывает пользователя с учетной записью у внешнего провайдера OIDC
+// Пара (Provider, Subject) однозначно определяет внешнюю учетную запись
+type ExternalIdentity struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null"`
+	Provider    string    `json:"provider" gorm:"not null"` // Issuer провайдера
+	Subject     string    `json:"subject" gorm:"not null"`  // Claim sub из ID-токена
+	Email       string    `json:"email"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OIDCAuthRequest представляет начатый вход через OIDC
+// Хранит PKCE verifier и nonce до возврата пользователя от провайдера
+type OIDCAuthRequest struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	UsedAt       *time.Time
+	CreatedAt    time.Time
+}
+
+// TableName задает имя таблицы для OIDCAuthRequest
+func (OIDCAuthRequest) TableName() string {
+	return "oidc_auth_requests"
+}
@@ -6,18 +6,36 @@ import (
 	"gorm.io/gorm"
 )
 
+// Роли пользователей
+const (
+	RoleUser    = "user"    // Обычный пользователь, работает только со своими данными
+	RoleSupport = "support" // Поддержка, может просматривать данные всех пользователей
+	RoleAdmin   = "admin"   // Администратор, управляет всеми пользователями
+
+	// Администратор платформы, управляет организациями.
+	// Остальные роли действуют только внутри организации пользователя
+	RoleSuperAdmin = "superadmin"
+)
+
 // User представляет модель пользователя в системе
 // Содержит основную информацию о пользователе и его учетных данных
 type User struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	Name         string         `json:"name" gorm:"not null"`
-	Email        string         `json:"email" gorm:"unique;not null"`
-	Age          int            `json:"age" gorm:"not null"`
-	PasswordHash string         `json:"-" gorm:"not null"` // Скрываем хеш пароля из JSON
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"` // Мягкое удаление
-	Orders       []Order        `json:"orders,omitempty" gorm:"foreignKey:UserID"`
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	OrgID           uint           `json:"org_id" gorm:"not null"` // Организация, которой принадлежит пользователь
+	Name            string         `json:"name" gorm:"not null"`
+	Email           string         `json:"email" gorm:"unique;not null"`
+	Age             int            `json:"age" gorm:"not null"`
+	PasswordHash    string         `json:"-" gorm:"not null"` // Скрываем хеш пароля из JSON
+	Role            string         `json:"role" gorm:"not null;default:user"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`                       // Пусто, пока email не подтвержден
+	TOTPSecret      string         `json:"-" gorm:"column:totp_secret"`             // Секрет TOTP в base32, задается при подключении 2FA
+	TOTPEnabledAt   *time.Time     `json:"-" gorm:"column:totp_enabled_at"`         // Пусто, пока 2FA не подтверждена первым кодом
+	TOTPLastStep    int64          `json:"-" gorm:"column:totp_last_step;not null"` // Последний принятый шаг TOTP, защищает от повтора кода
+	LockedUntil     *time.Time     `json:"locked_until,omitempty"`                  // До какого времени вход заблокирован после неудачных попыток
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"` // Мягкое удаление
+	Orders          []Order        `json:"orders,omitempty" gorm:"foreignKey:UserID"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) error {
@@ -31,14 +49,33 @@ func (u *User) BeforeUpdate(tx *gorm.DB) error {
 	return nil
 }
 
+// PasswordHistory хранит хеш одного из прежних паролей пользователя
+// Используется, чтобы запретить повторное использование последних паролей
+type PasswordHistory struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"not null"`
+	PasswordHash string `gorm:"not null"`
+	CreatedAt    time.Time
+}
+
+// TableName задает имя таблицы для PasswordHistory
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
+
 // UserResponse представляет данные пользователя для ответа API
 // Не включает конфиденциальную информацию
 type UserResponse struct {
-	ID        uint      `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Age       int       `json:"age"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               uint       `json:"id"`
+	OrgID            uint       `json:"org_id"`
+	Name             string     `json:"name"`
+	Email            string     `json:"email"`
+	Age              int        `json:"age"`
+	Role             string     `json:"role"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	LockedUntil      *time.Time `json:"locked_until,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
 }
 
 // CreateUserRequest представляет данные для создания нового пользователя
@@ -47,7 +84,7 @@ type CreateUserRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Age      int    `json:"age" binding:"required,gte=0,lte=130"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 }
 
 // UpdateUserRequest представляет данные для обновления пользователя
@@ -56,5 +93,21 @@ type UpdateUserRequest struct {
 	Name     string `json:"name,omitempty"`
 	Email    string `json:"email,omitempty" binding:"omitempty,email"`
 	Age      int    `json:"age,omitempty" binding:"omitempty,gte=0,lte=130"`
-	Password string `json:"password,omitempty" binding:"omitempty,min=6"`
+	Password string `json:"password,omitempty"`
+}
+
+// UpdateRoleRequest представляет данные для смены роли пользователя
+// Доступно только администраторам, роль superadmin выдает и снимает только superadmin
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user support admin superadmin"`
+}
+
+// LoginAttempt представляет неудачную попытку входа
+// Используется для ограничения перебора паролей по учетной записи и по IP
+type LoginAttempt struct {
+	ID        uint   `gorm:"primaryKey"`
+	Email     string `gorm:"not null"` // Email в нижнем регистре, в том числе незарегистрированный
+	IP        string `gorm:"column:ip;not null"`
+	Cleared   bool   `gorm:"not null;default:false"` // Попытка больше не учитывается для учетной записи (успешный вход или разблокировка)
+	CreatedAt time.Time
 }
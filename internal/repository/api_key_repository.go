@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository отвечает за хранение ключей API в БД
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *APIKeyRepository) WithContext(ctx context.Context) *APIKeyRepository {
+	return &APIKeyRepository{db: withContext(r.db, ctx)}
+}
+
+// WithTx возвращает репозиторий, работающий в рамках транзакции tx
+func (r *APIKeyRepository) WithTx(tx *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: tx}
+}
+
+// Create сохраняет новый ключ API
+func (r *APIKeyRepository) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+// GetByPrefix находит ключ по открытой части
+// Возвращает в том числе отозванные и просроченные ключи
+func (r *APIKeyRepository) GetByPrefix(prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Where("prefix = ?", prefix).First(&key).Error
+	return &key, err
+}
+
+// ListByUser возвращает все ключи пользователя, начиная с новых
+func (r *APIKeyRepository) ListByUser(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke отзывает ключ пользователя
+// Возвращает false если ключ не найден или уже отозван
+func (r *APIKeyRepository) Revoke(userID, id uint) (bool, error) {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAllForUser отзывает все действующие ключи пользователя
+// Возвращает количество отозванных ключей
+func (r *APIKeyRepository) RevokeAllForUser(userID uint) (int64, error) {
+	result := r.db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// TouchLastUsed обновляет время и IP последнего использования ключа
+// Запись выполняется не чаще раза в interval, чтобы не писать в БД на каждый запрос
+func (r *APIKeyRepository) TouchLastUsed(id uint, ip string, interval time.Duration) error {
+	now := time.Now()
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-interval)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// EmailVerificationRepository отвечает за хранение токенов подтверждения email в БД
+type EmailVerificationRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailVerificationRepository(db *gorm.DB) *EmailVerificationRepository {
+	return &EmailVerificationRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *EmailVerificationRepository) WithContext(ctx context.Context) *EmailVerificationRepository {
+	return &EmailVerificationRepository{db: withContext(r.db, ctx)}
+}
+
+// Create сохраняет новый токен подтверждения
+func (r *EmailVerificationRepository) Create(token *models.EmailVerificationToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetByHash находит токен по хешу
+func (r *EmailVerificationRepository) GetByHash(hash string) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	return &token, err
+}
+
+// MarkUsed помечает токен использованным
+// Возвращает false если токен уже был использован
+func (r *EmailVerificationRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&models.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// InvalidateForUser делает недействительными все неиспользованные токены пользователя
+func (r *EmailVerificationRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
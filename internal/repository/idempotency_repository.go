@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyRepository отвечает за хранение ключей идемпотентности в БД
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *IdempotencyRepository) WithContext(ctx context.Context) *IdempotencyRepository {
+	return &IdempotencyRepository{db: withContext(r.db, ctx)}
+}
+
+// Reserve пытается занять ключ для пользователя
+// Возвращает false если ключ уже занят другим запросом
+func (r *IdempotencyRepository) Reserve(record *models.IdempotencyKey) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetByKey находит запись по пользователю и ключу
+func (r *IdempotencyRepository) GetByKey(userID uint, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := r.db.Where("user_id = ? AND key = ?", userID, key).First(&record).Error
+	return &record, err
+}
+
+// Complete сохраняет ответ на запрос
+func (r *IdempotencyRepository) Complete(id uint, statusCode int, headers string, body []byte) error {
+	return r.db.Model(&models.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status_code":      statusCode,
+		"response_headers": headers,
+		"response_body":    body,
+	}).Error
+}
+
+// Delete удаляет запись, освобождая ключ для повторной попытки
+func (r *IdempotencyRepository) Delete(id uint) error {
+	return r.db.Delete(&models.IdempotencyKey{}, id).Error
+}
+
+// DeleteExpired удаляет записи с истекшим сроком хранения
+func (r *IdempotencyRepository) DeleteExpired() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{}).Error
+}
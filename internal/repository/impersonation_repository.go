@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ImpersonationRepository отвечает за журнал входов от имени пользователей
+// Сессии журнала принадлежат организации пользователя, запросы выполняются через ForOrg или System
+type ImpersonationRepository struct {
+	db *gorm.DB
+}
+
+func NewImpersonationRepository(db *gorm.DB) *ImpersonationRepository {
+	return &ImpersonationRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *ImpersonationRepository) WithContext(ctx context.Context) *ImpersonationRepository {
+	return &ImpersonationRepository{db: withContext(r.db, ctx)}
+}
+
+// ForOrg возвращает репозиторий, работающий только с журналом организации orgID
+func (r *ImpersonationRepository) ForOrg(orgID uint) *ImpersonationRepository {
+	return &ImpersonationRepository{db: WithOrg(r.db, orgID)}
+}
+
+// System возвращает репозиторий для проверки и завершения сессий по ID из токена
+func (r *ImpersonationRepository) System() *ImpersonationRepository {
+	return &ImpersonationRepository{db: WithoutTenant(r.db)}
+}
+
+// CreateSession сохраняет начатый вход от имени пользователя
+func (r *ImpersonationRepository) CreateSession(session *models.ImpersonationSession) error {
+	return r.db.Create(session).Error
+}
+
+// GetSession получает сессию из журнала по ID
+func (r *ImpersonationRepository) GetSession(id string) (*models.ImpersonationSession, error) {
+	var session models.ImpersonationSession
+	err := r.db.Where("id = ?", id).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// IsActive проверяет, что сессия не завершена и не истекла
+func (r *ImpersonationRepository) IsActive(id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.ImpersonationSession{}).
+		Where("id = ? AND ended_at IS NULL AND expires_at > ?", id, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// EndSession завершает сессию
+// Возвращает false если сессия уже была завершена
+func (r *ImpersonationRepository) EndSession(id string) (bool, error) {
+	result := r.db.Model(&models.ImpersonationSession{}).
+		Where("id = ? AND ended_at IS NULL", id).
+		Update("ended_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListSessions возвращает последние сессии из журнала, от новых к старым
+func (r *ImpersonationRepository) ListSessions(filter models.ImpersonationFilter) ([]models.ImpersonationSession, error) {
+	query := r.db.Model(&models.ImpersonationSession{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+
+	var sessions []models.ImpersonationSession
+	err := query.Order("created_at DESC").Limit(filter.Limit).Find(&sessions).Error
+	return sessions, err
+}
+
+// CreateEvent сохраняет запрос, выполненный от имени пользователя
+func (r *ImpersonationRepository) CreateEvent(event *models.ImpersonationEvent) error {
+	return r.db.Create(event).Error
+}
+
+// ListEvents возвращает запросы сессии в порядке выполнения
+func (r *ImpersonationRepository) ListEvents(sessionID string) ([]models.ImpersonationEvent, error) {
+	var events []models.ImpersonationEvent
+	err := r.db.Where("session_id = ?", sessionID).Order("created_at, id").Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// keysetCreatedAt - время создания тестовых записей: три записи создаются в одну и ту же секунду
+var keysetCreatedAt = []time.Time{
+	time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC),
+}
+
+// keysetExpected возвращает ID записей в порядке (created_at DESC, id DESC) для keysetCreatedAt,
+// если записи созданы в этом порядке с ID от first
+func keysetExpected(first uint) []uint {
+	return []uint{first + 4, first + 3, first + 2, first + 1, first}
+}
+
+// setCreatedAt задает время создания записи в обход BeforeCreate, который всегда ставит текущее время
+func setCreatedAt(t *testing.T, db *gorm.DB, model interface{}, id uint, createdAt time.Time) {
+	t.Helper()
+
+	if err := WithoutTenant(db).Model(model).Where("id = ?", id).UpdateColumn("created_at", createdAt).Error; err != nil {
+		t.Fatalf("set created_at: %v", err)
+	}
+}
+
+// pageThrough проходит по всем страницам размером limit, передавая курсор последней записи страницы
+func pageThrough(t *testing.T, limit int, list func(after *utils.Cursor) ([]uint, []time.Time, error)) []uint {
+	t.Helper()
+
+	var ids []uint
+	var after *utils.Cursor
+	for page := 0; page < 10; page++ {
+		pageIDs, createdAt, err := list(after)
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		if len(pageIDs) > limit {
+			t.Fatalf("page %d has %d records, limit %d", page, len(pageIDs), limit)
+		}
+		ids = append(ids, pageIDs...)
+		if len(pageIDs) < limit {
+			return ids
+		}
+		last := len(pageIDs) - 1
+		after = &utils.Cursor{CreatedAt: createdAt[last], ID: pageIDs[last]}
+	}
+	t.Fatal("pagination did not terminate")
+	return nil
+}
+
+func TestOrderRepositoryListAfterBreaksTiesByID(t *testing.T) {
+	db := newTestDB(t)
+	userA, userB, orderA, _ := seedTenants(t, db)
+	orders := NewOrderRepository(db).ForOrg(testOrgA)
+
+	var first uint
+	for i, createdAt := range keysetCreatedAt {
+		order := &models.Order{UserID: userA.ID, Status: models.OrderStatusPending, Currency: "USD"}
+		if err := orders.Create(order, userA.ID); err != nil {
+			t.Fatalf("create order: %v", err)
+		}
+		setCreatedAt(t, db, &models.Order{}, order.ID, createdAt)
+		if i == 0 {
+			first = order.ID
+		}
+	}
+	// Заказ другой организации с тем же временем не должен попасть в выборку
+	other := &models.Order{UserID: userB.ID, Status: models.OrderStatusPending, Currency: "USD"}
+	if err := NewOrderRepository(db).ForOrg(testOrgB).Create(other, userB.ID); err != nil {
+		t.Fatalf("create order B: %v", err)
+	}
+	setCreatedAt(t, db, &models.Order{}, other.ID, keysetCreatedAt[2])
+
+	// Заказ из seedTenants создан текущим временем и идет первым
+	expected := append([]uint{orderA.ID}, keysetExpected(first)...)
+	for _, limit := range []int{1, 2, 3, 10} {
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			got := pageThrough(t, limit, func(after *utils.Cursor) ([]uint, []time.Time, error) {
+				page, err := orders.ListAfter(models.OrderFilter{}, after, limit)
+				ids := make([]uint, len(page))
+				createdAt := make([]time.Time, len(page))
+				for i, order := range page {
+					ids[i], createdAt[i] = order.ID, order.CreatedAt
+				}
+				return ids, createdAt, err
+			})
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("got %v, want %v", got, expected)
+			}
+		})
+	}
+}
+
+func TestUserRepositoryListAfterBreaksTiesByID(t *testing.T) {
+	db := newTestDB(t)
+	seedTenants(t, db)
+	users := NewUserRepository(db).ForOrg(testOrgA)
+
+	// Пользователь из seedTenants создан текущим временем и идет первым
+	existing, _, err := users.List(models.PaginationParams{Page: 1, Limit: 10})
+	if err != nil || len(existing) != 1 {
+		t.Fatalf("list seeded users: %v, %v", existing, err)
+	}
+
+	var first uint
+	for i, createdAt := range keysetCreatedAt {
+		user := &models.User{Name: "User", Email: fmt.Sprintf("user%d@a.example", i), Age: 30, PasswordHash: "hash", Role: models.RoleUser}
+		if err := users.Create(user); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		setCreatedAt(t, db, &models.User{}, user.ID, createdAt)
+		if i == 0 {
+			first = user.ID
+		}
+	}
+
+	expected := append([]uint{existing[0].ID}, keysetExpected(first)...)
+	for _, limit := range []int{1, 2, 3, 10} {
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			got := pageThrough(t, limit, func(after *utils.Cursor) ([]uint, []time.Time, error) {
+				page, err := users.ListAfter(models.PaginationParams{}, after, limit)
+				ids := make([]uint, len(page))
+				createdAt := make([]time.Time, len(page))
+				for i, user := range page {
+					ids[i], createdAt[i] = user.ID, user.CreatedAt
+				}
+				return ids, createdAt, err
+			})
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("got %v, want %v", got, expected)
+			}
+		})
+	}
+}
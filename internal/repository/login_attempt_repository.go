@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// LoginAttemptRepository отвечает за хранение неудачных попыток входа
+type LoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *LoginAttemptRepository) WithContext(ctx context.Context) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: withContext(r.db, ctx)}
+}
+
+// Create сохраняет неудачную попытку входа
+func (r *LoginAttemptRepository) Create(attempt *models.LoginAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+// RecentByEmail возвращает учитываемые неудачные попытки для email начиная с since
+// Попытки отсортированы от новых к старым
+func (r *LoginAttemptRepository) RecentByEmail(email string, since time.Time) ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+	err := r.db.Where("email = ? AND cleared = ? AND created_at > ?", email, false, since).
+		Order("created_at DESC").
+		Find(&attempts).Error
+	return attempts, err
+}
+
+// CountByIP считает неудачные попытки с IP начиная с since
+// Сброс попыток учетной записи на этот счетчик не влияет
+func (r *LoginAttemptRepository) CountByIP(ip string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.LoginAttempt{}).
+		Where("ip = ? AND created_at > ?", ip, since).
+		Count(&count).Error
+	return count, err
+}
+
+// ClearEmail перестает учитывать неудачные попытки для email
+// Вызывается после успешного входа и при разблокировке администратором
+func (r *LoginAttemptRepository) ClearEmail(email string) error {
+	return r.db.Model(&models.LoginAttempt{}).
+		Where("email = ? AND cleared = ?", email, false).
+		Update("cleared", true).Error
+}
+
+// DeleteOlderThan удаляет попытки, которые уже не влияют на ограничения
+func (r *LoginAttemptRepository) DeleteOlderThan(before time.Time) error {
+	return r.db.Where("created_at < ?", before).Delete(&models.LoginAttempt{}).Error
+}
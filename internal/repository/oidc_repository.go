@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// OIDCRepository отвечает за хранение внешних учетных записей и начатых входов через OIDC
+type OIDCRepository struct {
+	db *gorm.DB
+}
+
+func NewOIDCRepository(db *gorm.DB) *OIDCRepository {
+	return &OIDCRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *OIDCRepository) WithContext(ctx context.Context) *OIDCRepository {
+	return &OIDCRepository{db: withContext(r.db, ctx)}
+}
+
+// CreateAuthRequest сохраняет начатый вход
+func (r *OIDCRepository) CreateAuthRequest(request *models.OIDCAuthRequest) error {
+	return r.db.Create(request).Error
+}
+
+// GetAuthRequestByHash находит начатый вход по хешу state
+func (r *OIDCRepository) GetAuthRequestByHash(hash string) (*models.OIDCAuthRequest, error) {
+	var request models.OIDCAuthRequest
+	err := r.db.Where("state_hash = ?", hash).First(&request).Error
+	return &request, err
+}
+
+// MarkAuthRequestUsed помечает начатый вход использованным
+// Возвращает false если state уже был использован
+func (r *OIDCRepository) MarkAuthRequestUsed(id uint) (bool, error) {
+	result := r.db.Model(&models.OIDCAuthRequest{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteExpiredAuthRequests удаляет незавершенные входы с истекшим сроком
+func (r *OIDCRepository) DeleteExpiredAuthRequests() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCAuthRequest{}).Error
+}
+
+// GetIdentity находит внешнюю учетную запись по провайдеру и subject
+func (r *OIDCRepository) GetIdentity(provider, subject string) (*models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	return &identity, err
+}
+
+// CreateIdentity привязывает внешнюю учетную запись к существующему пользователю
+func (r *OIDCRepository) CreateIdentity(identity *models.ExternalIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// CreateUserWithIdentity создает пользователя и его внешнюю учетную запись в одной транзакции
+func (r *OIDCRepository) CreateUserWithIdentity(user *models.User, identity *models.ExternalIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// TouchIdentity обновляет время последнего входа и email внешней учетной записи
+func (r *OIDCRepository) TouchIdentity(id uint, email string) error {
+	return r.db.Model(&models.ExternalIdentity{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_login_at": time.Now(), "email": email}).Error
+}
@@ -1,12 +1,19 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"go-crud-api/internal/models"
+	"go-crud-api/internal/utils"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // OrderRepository отвечает за работу с данными заказов в БД
+// Заказы принадлежат организациям: запросы выполняются через ForOrg или System,
+// без них TenantPlugin отклоняет запрос
 type OrderRepository struct {
 	db *gorm.DB
 }
@@ -15,19 +22,70 @@ func NewOrderRepository(db *gorm.DB) *OrderRepository {
 	return &OrderRepository{db: db}
 }
 
-// Create сохраняет новый заказ в БД
-func (r *OrderRepository) Create(order *models.Order) error {
-	return r.db.Create(order).Error
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *OrderRepository) WithContext(ctx context.Context) *OrderRepository {
+	return &OrderRepository{db: withContext(r.db, ctx)}
+}
+
+// ForOrg возвращает репозиторий, работающий только с заказами организации orgID
+func (r *OrderRepository) ForOrg(orgID uint) *OrderRepository {
+	return &OrderRepository{db: WithOrg(r.db, orgID)}
+}
+
+// System возвращает репозиторий для операций системного уровня, не ограниченных организацией
+func (r *OrderRepository) System() *OrderRepository {
+	return &OrderRepository{db: WithoutTenant(r.db)}
+}
+
+// WithTx возвращает репозиторий, работающий в рамках транзакции tx
+func (r *OrderRepository) WithTx(tx *gorm.DB) *OrderRepository {
+	return &OrderRepository{db: tx}
+}
+
+// Transaction выполняет fn в транзакции БД
+// Транзакцию можно передать в другие репозитории через их метод WithTx
+func (r *OrderRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
+// Create сохраняет новый заказ вместе с позициями в БД
+// Вместе с заказом в истории фиксируется его начальный статус
+func (r *OrderRepository) Create(order *models.Order, changedBy uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.OrderStatusHistory{
+			OrderID:   order.ID,
+			ToStatus:  order.Status,
+			ChangedBy: changedBy,
+		}).Error
+	})
 }
 
 // GetByID находит заказ по ID
 // Возвращает ошибку если заказ не найден
 func (r *OrderRepository) GetByID(id uint) (*models.Order, error) {
 	var order models.Order
-	result := r.db.First(&order, id)
+	result := r.db.Preload("Items").First(&order, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &order, nil
+}
+
+// GetByIDForUpdate находит заказ по ID и блокирует его строку до конца транзакции
+func (r *OrderRepository) GetByIDForUpdate(id uint) (*models.Order, error) {
+	var order models.Order
+	result := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id)
 	if result.Error != nil {
 		return nil, result.Error
 	}
+
+	if err := r.db.Where("order_id = ?", order.ID).Find(&order.Items).Error; err != nil {
+		return nil, err
+	}
 	return &order, nil
 }
 
@@ -35,14 +93,36 @@ func (r *OrderRepository) GetByID(id uint) (*models.Order, error) {
 // Возвращает пустой слайс если заказов нет
 func (r *OrderRepository) GetByUserID(userID uint) ([]models.Order, error) {
 	var orders []models.Order
-	err := r.db.Where("user_id = ?", userID).Find(&orders).Error
+	err := r.db.Preload("Items").Where("user_id = ?", userID).Find(&orders).Error
 	return orders, err
 }
 
 // Update обновляет данные заказа
-// Обновляет только непустые поля
+// Позиции заказа заменяются целиком в одной транзакции
 func (r *OrderRepository) Update(order *models.Order) error {
-	return r.db.Save(order).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// В отличие от Save не создает запись, если заказа нет в организации
+		result := tx.Select("*").Omit("Items", "User").Updates(order)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("order_id = ?", order.ID).Delete(&models.OrderItem{}).Error; err != nil {
+			return err
+		}
+
+		for i := range order.Items {
+			order.Items[i].ID = 0
+			order.Items[i].OrderID = order.ID
+		}
+		if len(order.Items) == 0 {
+			return nil
+		}
+		return tx.Create(&order.Items).Error
+	})
 }
 
 // Delete удаляет заказ по ID
@@ -51,19 +131,109 @@ func (r *OrderRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Order{}, id).Error
 }
 
-// List возвращает список всех заказов с пагинацией
-// Поддерживает сортировку и фильтрацию
-func (r *OrderRepository) List(page, limit int) ([]models.Order, int64, error) {
+// List возвращает заказы с пагинацией
+// Поддерживает фильтрацию по пользователю, статусу, товару, сумме и дате создания,
+// а также сортировку по нескольким полям
+func (r *OrderRepository) List(filter models.OrderFilter) ([]models.Order, int64, error) {
 	var orders []models.Order
 	var total int64
 
-	offset := (page - 1) * limit
+	query := applyOrderFilters(r.db.Model(&models.Order{}), filter)
 
-	err := r.db.Model(&models.Order{}).Count(&total).Error
-	if err != nil {
+	// Получаем общее количество записей
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	err = r.db.Offset(offset).Limit(limit).Find(&orders).Error
-	return orders, total, err
+	// Применяем сортировку. Имена колонок проверяются в сервисе,
+	// ID в конце делает порядок стабильным между страницами
+	for _, field := range filter.Sort {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: field.Column}, Desc: field.Desc})
+	}
+	query = query.Order("id DESC")
+
+	// Применяем пагинацию
+	offset := (filter.Page - 1) * filter.Limit
+	if err := query.Preload("Items").Offset(offset).Limit(filter.Limit).Find(&orders).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return orders, total, nil
+}
+
+// ListAfter возвращает до limit заказов, созданных раньше позиции курсора
+// Использует keyset-пагинацию по (created_at, id) без OFFSET и COUNT(*).
+// Сортировка из фильтра не применяется. Если after == nil, возвращается первая страница
+func (r *OrderRepository) ListAfter(filter models.OrderFilter, after *utils.Cursor, limit int) ([]models.Order, error) {
+	var orders []models.Order
+
+	query := applyOrderFilters(r.db.Model(&models.Order{}), filter)
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
+	}
+
+	err := query.Preload("Items").Order("created_at DESC, id DESC").Limit(limit).Find(&orders).Error
+	return orders, err
+}
+
+// applyOrderFilters добавляет в запрос фильтры списка заказов
+func applyOrderFilters(query *gorm.DB, filter models.OrderFilter) *gorm.DB {
+	if filter.UserID > 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.ProductID > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND oi.product_id = ?)", filter.ProductID)
+	}
+	if filter.Product != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND oi.product ILIKE ?)", "%"+filter.Product+"%")
+	}
+	if filter.Currency != "" {
+		query = query.Where("currency = ?", filter.Currency)
+	}
+	if filter.MinTotal != nil {
+		query = query.Where("total >= ?", *filter.MinTotal)
+	}
+	if filter.MaxTotal != nil {
+		query = query.Where("total <= ?", *filter.MaxTotal)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		query = query.Where("created_at <= ?", filter.CreatedTo)
+	}
+	return query
+}
+
+// UpdateStatus переводит заказ в новый статус и сохраняет запись в истории
+// Обновление выполняется только если текущий статус совпадает с history.FromStatus,
+// поэтому параллельные переходы не могут перезаписать друг друга.
+// Возвращает false если статус заказа успел измениться
+func (r *OrderRepository) UpdateStatus(orderID uint, history *models.OrderStatusHistory) (bool, error) {
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", orderID, *history.FromStatus).
+			Updates(map[string]interface{}{"status": history.ToStatus, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		updated = true
+		return tx.Create(history).Error
+	})
+	return updated, err
+}
+
+// GetStatusHistory возвращает историю статусов заказа в хронологическом порядке
+func (r *OrderRepository) GetStatusHistory(orderID uint) ([]models.OrderStatusHistory, error) {
+	var history []models.OrderStatusHistory
+	err := r.db.Where("order_id = ?", orderID).Order("created_at, id").Find(&history).Error
+	return history, err
 }
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// OrganizationRepository отвечает за работу с организациями в БД
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *OrganizationRepository) WithContext(ctx context.Context) *OrganizationRepository {
+	return &OrganizationRepository{db: withContext(r.db, ctx)}
+}
+
+// Create сохраняет новую организацию
+func (r *OrganizationRepository) Create(org *models.Organization) error {
+	return r.db.Create(org).Error
+}
+
+// GetByID находит организацию по ID
+func (r *OrganizationRepository) GetByID(id uint) (*models.Organization, error) {
+	var org models.Organization
+	err := r.db.First(&org, id).Error
+	return &org, err
+}
+
+// List возвращает все организации
+func (r *OrganizationRepository) List() ([]models.Organization, error) {
+	var orgs []models.Organization
+	err := r.db.Order("id").Find(&orgs).Error
+	return orgs, err
+}
+
+// CheckSlugExists проверяет, занят ли slug другой организацией
+func (r *OrganizationRepository) CheckSlugExists(slug string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Organization{}).Where("slug = ?", slug).Count(&count).Error
+	return count > 0, err
+}
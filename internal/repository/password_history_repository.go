@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PasswordHistoryRepository отвечает за хранение хешей прежних паролей
+type PasswordHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *PasswordHistoryRepository) WithContext(ctx context.Context) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{db: withContext(r.db, ctx)}
+}
+
+// WithTx возвращает репозиторий, работающий в рамках транзакции tx
+func (r *PasswordHistoryRepository) WithTx(tx *gorm.DB) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{db: tx}
+}
+
+// Create сохраняет хеш прежнего пароля
+func (r *PasswordHistoryRepository) Create(entry *models.PasswordHistory) error {
+	return r.db.Create(entry).Error
+}
+
+// Recent возвращает limit последних хешей пользователя, от новых к старым
+func (r *PasswordHistoryRepository) Recent(userID uint, limit int) ([]models.PasswordHistory, error) {
+	var entries []models.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
+
+// Trim удаляет записи пользователя старше keep последних
+func (r *PasswordHistoryRepository) Trim(userID uint, keep int) error {
+	recent := r.db.Model(&models.PasswordHistory{}).
+		Select("id").
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(keep)
+	return r.db.Where("user_id = ? AND id NOT IN (?)", userID, recent).
+		Delete(&models.PasswordHistory{}).Error
+}
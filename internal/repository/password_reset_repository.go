@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetRepository отвечает за хранение токенов сброса пароля в БД
+type PasswordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *PasswordResetRepository) WithContext(ctx context.Context) *PasswordResetRepository {
+	return &PasswordResetRepository{db: withContext(r.db, ctx)}
+}
+
+// WithTx возвращает репозиторий, работающий в рамках транзакции tx
+func (r *PasswordResetRepository) WithTx(tx *gorm.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: tx}
+}
+
+// Transaction выполняет fn в транзакции БД
+// Транзакцию можно передать в другие репозитории через их метод WithTx
+func (r *PasswordResetRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
+// Create сохраняет новый токен сброса пароля
+func (r *PasswordResetRepository) Create(token *models.PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetByHash находит токен по хешу
+func (r *PasswordResetRepository) GetByHash(hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	return &token, err
+}
+
+// MarkUsed помечает токен использованным
+// Возвращает false если токен уже был использован
+func (r *PasswordResetRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// InvalidateForUser делает недействительными все неиспользованные токены пользователя
+func (r *PasswordResetRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProductRepository отвечает за работу с каталогом товаров в БД
+type ProductRepository struct {
+	db *gorm.DB
+}
+
+func NewProductRepository(db *gorm.DB) *ProductRepository {
+	return &ProductRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *ProductRepository) WithContext(ctx context.Context) *ProductRepository {
+	return &ProductRepository{db: withContext(r.db, ctx)}
+}
+
+// WithTx возвращает репозиторий, работающий в рамках транзакции tx
+func (r *ProductRepository) WithTx(tx *gorm.DB) *ProductRepository {
+	return &ProductRepository{db: tx}
+}
+
+// Create сохраняет новый товар в БД
+func (r *ProductRepository) Create(product *models.Product) error {
+	return r.db.Create(product).Error
+}
+
+// GetByID находит товар по ID
+// Возвращает ошибку если товар не найден
+func (r *ProductRepository) GetByID(id uint) (*models.Product, error) {
+	var product models.Product
+	err := r.db.First(&product, id).Error
+	return &product, err
+}
+
+// GetByIDs находит товары по списку ID
+// Отсутствующие товары просто не попадают в результат
+func (r *ProductRepository) GetByIDs(ids []uint) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.Where("id IN ?", ids).Find(&products).Error
+	return products, err
+}
+
+// GetByIDsForUpdate находит товары по списку ID и блокирует их строки до конца транзакции
+// Строки блокируются в порядке ID, чтобы параллельные заказы не получили deadlock
+func (r *ProductRepository) GetByIDsForUpdate(ids []uint) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&products).Error
+	return products, err
+}
+
+// ReserveStock атомарно списывает quantity единиц товара со склада
+// Возвращает false если остатка недостаточно
+func (r *ProductRepository) ReserveStock(id uint, quantity int) (bool, error) {
+	result := r.db.Model(&models.Product{}).
+		Where("id = ? AND stock >= ?", id, quantity).
+		UpdateColumn("stock", gorm.Expr("stock - ?", quantity))
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseStock возвращает quantity единиц товара на склад
+// Используется при отмене и удалении заказа
+func (r *ProductRepository) ReleaseStock(id uint, quantity int) error {
+	return r.db.Unscoped().Model(&models.Product{}).
+		Where("id = ?", id).
+		UpdateColumn("stock", gorm.Expr("stock + ?", quantity)).Error
+}
+
+// ListLowStock возвращает активные товары с остатком не больше threshold
+// Товары с наименьшим остатком идут первыми
+func (r *ProductRepository) ListLowStock(threshold int) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.Where("active = ? AND stock <= ?", true, threshold).
+		Order("stock, id").
+		Find(&products).Error
+	return products, err
+}
+
+// Update обновляет данные товара
+func (r *ProductRepository) Update(product *models.Product) error {
+	return r.db.Save(product).Error
+}
+
+// Delete удаляет товар по ID
+// Использует мягкое удаление (soft delete), чтобы не ломать историю заказов
+func (r *ProductRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Product{}, id).Error
+}
+
+// List возвращает активные товары с пагинацией и поиском по названию и описанию
+func (r *ProductRepository) List(params models.ProductListParams) ([]models.Product, int64, error) {
+	var products []models.Product
+	var total int64
+
+	query := r.db.Model(&models.Product{}).Where("active = ?", true)
+
+	// Применяем поиск
+	if params.Query != "" {
+		pattern := "%" + params.Query + "%"
+		query = query.Where("name ILIKE ? OR description ILIKE ?", pattern, pattern)
+	}
+
+	// Получаем общее количество записей
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Применяем пагинацию
+	offset := (params.Page - 1) * params.Limit
+	if err := query.Order("name, id").Offset(offset).Limit(params.Limit).Find(&products).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return products, total, nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrTenantRequired = errors.New("query on tenant data without organization")
+	ErrTenantMismatch = errors.New("record belongs to another organization")
+)
+
+// tenantContextKey - ключ, под которым в контексте запроса к БД хранится организация
+type tenantContextKey struct{}
+
+// tenantScope описывает, к данным какой организации относится запрос
+// system - запрос системного уровня, не ограниченный организацией (вход, обновление токенов)
+type tenantScope struct {
+	orgID  uint
+	system bool
+}
+
+// WithOrg возвращает подключение, все запросы которого ограничены организацией orgID
+func WithOrg(db *gorm.DB, orgID uint) *gorm.DB {
+	return db.WithContext(context.WithValue(db.Statement.Context, tenantContextKey{}, tenantScope{orgID: orgID}))
+}
+
+// WithoutTenant возвращает подключение для запросов системного уровня, не ограниченных организацией
+// Используется там, где организация еще неизвестна или определяется по самой записи:
+// вход по email, обновление токенов, подтверждение email по ссылке
+func WithoutTenant(db *gorm.DB) *gorm.DB {
+	return db.WithContext(context.WithValue(db.Statement.Context, tenantContextKey{}, tenantScope{system: true}))
+}
+
+// withContext привязывает подключение к ctx, сохраняя организацию, выбранную WithOrg или WithoutTenant
+// ctx запроса несет span трассировки, поэтому запросы к БД становятся частью трассировки запроса
+func withContext(db *gorm.DB, ctx context.Context) *gorm.DB {
+	if scope, ok := db.Statement.Context.Value(tenantContextKey{}).(tenantScope); ok {
+		ctx = context.WithValue(ctx, tenantContextKey{}, scope)
+	}
+	return db.WithContext(ctx)
+}
+
+// withTx переносит в транзакцию tx организацию, выбранную для db
+// Транзакцию может начать репозиторий без организации, а таблицы пользователей ее требуют
+func withTx(db, tx *gorm.DB) *gorm.DB {
+	if scope, ok := db.Statement.Context.Value(tenantContextKey{}).(tenantScope); ok {
+		return tx.WithContext(context.WithValue(tx.Statement.Context, tenantContextKey{}, scope))
+	}
+	return tx
+}
+
+// TenantPlugin ограничивает запросы к таблицам с колонкой org_id организацией из контекста
+// Запрос к такой таблице без WithOrg или WithoutTenant завершается ошибкой ErrTenantRequired,
+// поэтому забытый фильтр не может вернуть чужие записи.
+// Raw и Exec плагин не проверяет: условие org_id в таком SQL добавляется вручную
+type TenantPlugin struct{}
+
+// Name возвращает имя плагина для GORM
+func (TenantPlugin) Name() string {
+	return "tenant"
+}
+
+// Initialize регистрирует проверки организации перед запросами GORM
+func (TenantPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("tenant:create", tenantCreate); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:query", tenantFilter); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:update", tenantFilter); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenant:delete", tenantFilter); err != nil {
+		return err
+	}
+	return callbacks.Row().Before("gorm:row").Register("tenant:row", tenantFilter)
+}
+
+// tenantField возвращает поле OrgID модели запроса и организацию из контекста
+// Возвращает nil, если модель не принадлежит организациям
+func tenantField(db *gorm.DB) (*schema.Field, tenantScope, bool) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil, tenantScope{}, false
+	}
+	field := db.Statement.Schema.LookUpField("OrgID")
+	if field == nil {
+		return nil, tenantScope{}, false
+	}
+
+	scope, ok := db.Statement.Context.Value(tenantContextKey{}).(tenantScope)
+	if !ok {
+		db.AddError(fmt.Errorf("%w: %s", ErrTenantRequired, db.Statement.Schema.Table))
+		return nil, tenantScope{}, false
+	}
+	return field, scope, true
+}
+
+// tenantFilter добавляет условие org_id в SELECT, UPDATE и DELETE
+func tenantFilter(db *gorm.DB) {
+	field, scope, ok := tenantField(db)
+	if !ok || scope.system {
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: scope.orgID},
+	}})
+}
+
+// tenantCreate заполняет org_id новых записей организацией из контекста
+// Запись с org_id другой организации не сохраняется
+func tenantCreate(db *gorm.DB) {
+	field, scope, ok := tenantField(db)
+	if !ok || scope.system {
+		return
+	}
+
+	ctx := db.Statement.Context
+	assign := func(value reflect.Value) {
+		current, zero := field.ValueOf(ctx, value)
+		if zero {
+			if err := field.Set(ctx, value, scope.orgID); err != nil {
+				db.AddError(err)
+			}
+			return
+		}
+		if orgID, _ := current.(uint); orgID != scope.orgID {
+			db.AddError(ErrTenantMismatch)
+		}
+	}
+
+	value := db.Statement.ReflectValue
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			assign(reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		assign(value)
+	}
+}
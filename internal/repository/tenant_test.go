@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testOrgA uint = 1
+	testOrgB uint = 2
+)
+
+// newTestDB открывает БД SQLite в памяти с TenantPlugin и таблицами пользователей и заказов
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	// Каждое соединение с :memory: открывает отдельную БД
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&models.Organization{}, &models.User{}, &models.Order{}, &models.OrderItem{}, &models.OrderStatusHistory{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Use(TenantPlugin{}); err != nil {
+		t.Fatalf("register tenant plugin: %v", err)
+	}
+	return db
+}
+
+// seedTenants создает по пользователю с заказом в организациях A и B
+func seedTenants(t *testing.T, db *gorm.DB) (userA, userB *models.User, orderA, orderB *models.Order) {
+	t.Helper()
+
+	users := NewUserRepository(db)
+	orders := NewOrderRepository(db)
+	userA = &models.User{Name: "Alice", Email: "alice@a.example", Age: 30, PasswordHash: "hash", Role: models.RoleUser}
+	userB = &models.User{Name: "Bob", Email: "bob@b.example", Age: 30, PasswordHash: "hash", Role: models.RoleUser}
+	if err := users.ForOrg(testOrgA).Create(userA); err != nil {
+		t.Fatalf("create user A: %v", err)
+	}
+	if err := users.ForOrg(testOrgB).Create(userB); err != nil {
+		t.Fatalf("create user B: %v", err)
+	}
+
+	orderA = &models.Order{UserID: userA.ID, Status: models.OrderStatusPending, Currency: "USD"}
+	orderB = &models.Order{UserID: userB.ID, Status: models.OrderStatusPending, Currency: "USD"}
+	if err := orders.ForOrg(testOrgA).Create(orderA, userA.ID); err != nil {
+		t.Fatalf("create order A: %v", err)
+	}
+	if err := orders.ForOrg(testOrgB).Create(orderB, userB.ID); err != nil {
+		t.Fatalf("create order B: %v", err)
+	}
+	return userA, userB, orderA, orderB
+}
+
+func TestTenantPluginRejectsUnscopedQueries(t *testing.T) {
+	db := newTestDB(t)
+	seedTenants(t, db)
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"user get", func() error { _, err := NewUserRepository(db).GetByID(1); return err }},
+		{"user list", func() error {
+			_, _, err := NewUserRepository(db).List(models.PaginationParams{Page: 1, Limit: 10})
+			return err
+		}},
+		{"user create", func() error {
+			return NewUserRepository(db).Create(&models.User{Name: "Eve", Email: "eve@example.com", PasswordHash: "hash"})
+		}},
+		{"user delete", func() error { return NewUserRepository(db).Delete(1) }},
+		{"order get", func() error { _, err := NewOrderRepository(db).GetByID(1); return err }},
+		{"order by user", func() error { _, err := NewOrderRepository(db).GetByUserID(1); return err }},
+		{"order delete", func() error { return NewOrderRepository(db).Delete(1) }},
+		{"order count", func() error { var n int64; return db.Model(&models.Order{}).Count(&n).Error }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, ErrTenantRequired) {
+				t.Fatalf("expected ErrTenantRequired, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTenantPluginAllowsSystemQueries(t *testing.T) {
+	db := newTestDB(t)
+	_, userB, _, _ := seedTenants(t, db)
+
+	user, err := NewUserRepository(db).System().GetByEmail(userB.Email)
+	if err != nil {
+		t.Fatalf("system lookup: %v", err)
+	}
+	if user.OrgID != testOrgB {
+		t.Fatalf("expected org %d, got %d", testOrgB, user.OrgID)
+	}
+}
+
+func TestUserRepositoryForOrgIsolation(t *testing.T) {
+	db := newTestDB(t)
+	userA, userB, _, _ := seedTenants(t, db)
+	repoA := NewUserRepository(db).ForOrg(testOrgA)
+
+	if _, err := repoA.GetByID(userB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("read of another org's user: expected ErrRecordNotFound, got %v", err)
+	}
+	if _, err := repoA.GetByEmail(userB.Email); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("lookup of another org's email: expected ErrRecordNotFound, got %v", err)
+	}
+
+	users, total, err := repoA.List(models.PaginationParams{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 1 || len(users) != 1 || users[0].ID != userA.ID {
+		t.Fatalf("list returned users of another org: total=%d users=%v", total, users)
+	}
+
+	stolen := *userB
+	stolen.Name = "Mallory"
+	if err := repoA.Update(&stolen); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("update of another org's user: expected ErrRecordNotFound, got %v", err)
+	}
+	if err := repoA.SetLockedUntil(userB.ID, nil); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if err := repoA.UpdatePasswordHash(userB.ID, "stolen"); err != nil {
+		t.Fatalf("update password hash: %v", err)
+	}
+	if err := repoA.Delete(userB.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	got, err := NewUserRepository(db).ForOrg(testOrgB).GetByID(userB.ID)
+	if err != nil {
+		t.Fatalf("user of org B was deleted by org A: %v", err)
+	}
+	if got.Name != userB.Name || got.PasswordHash != userB.PasswordHash {
+		t.Fatalf("user of org B was modified by org A: %+v", got)
+	}
+
+	if err := repoA.Create(&models.User{OrgID: testOrgB, Name: "Eve", Email: "eve@example.com", PasswordHash: "hash"}); !errors.Is(err, ErrTenantMismatch) {
+		t.Fatalf("create in another org: expected ErrTenantMismatch, got %v", err)
+	}
+}
+
+func TestOrderRepositoryForOrgIsolation(t *testing.T) {
+	db := newTestDB(t)
+	userA, userB, orderA, orderB := seedTenants(t, db)
+	repoA := NewOrderRepository(db).ForOrg(testOrgA)
+
+	if _, err := repoA.GetByID(orderB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("read of another org's order: expected ErrRecordNotFound, got %v", err)
+	}
+	if orders, err := repoA.GetByUserID(userB.ID); err != nil || len(orders) != 0 {
+		t.Fatalf("orders of another org's user: got %v, %v", orders, err)
+	}
+
+	orders, total, err := repoA.List(models.OrderFilter{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 1 || len(orders) != 1 || orders[0].ID != orderA.ID {
+		t.Fatalf("list returned orders of another org: total=%d orders=%v", total, orders)
+	}
+
+	stolen := *orderB
+	stolen.Currency = "EUR"
+	if err := repoA.Update(&stolen); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("update of another org's order: expected ErrRecordNotFound, got %v", err)
+	}
+
+	from := models.OrderStatusPending
+	updated, err := repoA.UpdateStatus(orderB.ID, &models.OrderStatusHistory{OrderID: orderB.ID, FromStatus: &from, ToStatus: models.OrderStatusPaid, ChangedBy: userA.ID})
+	if err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	if updated {
+		t.Fatal("status of another org's order was changed")
+	}
+
+	if err := repoA.Delete(orderB.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	got, err := NewOrderRepository(db).ForOrg(testOrgB).GetByID(orderB.ID)
+	if err != nil {
+		t.Fatalf("order of org B was deleted by org A: %v", err)
+	}
+	if got.Currency != orderB.Currency || got.Status != models.OrderStatusPending {
+		t.Fatalf("order of org B was modified by org A: %+v", got)
+	}
+
+	if err := repoA.Create(&models.Order{OrgID: testOrgB, UserID: userA.ID, Status: models.OrderStatusPending, Currency: "USD"}, userA.ID); !errors.Is(err, ErrTenantMismatch) {
+		t.Fatalf("create in another org: expected ErrTenantMismatch, got %v", err)
+	}
+}
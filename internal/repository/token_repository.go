@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-crud-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// TokenRepository отвечает за хранение сессий, refresh-токенов
+// и отозванных access-токенов в БД
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *TokenRepository) WithContext(ctx context.Context) *TokenRepository {
+	return &TokenRepository{db: withContext(r.db, ctx)}
+}
+
+// WithTx возвращает репозиторий, работающий в рамках транзакции tx
+func (r *TokenRepository) WithTx(tx *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: tx}
+}
+
+// CreateSession создает новую сессию вместе с первым refresh-токеном
+// Обе записи сохраняются в одной транзакции
+func (r *TokenRepository) CreateSession(session *models.Session, token *models.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// GetSession находит сессию по ID
+func (r *TokenRepository) GetSession(id string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Where("id = ?", id).First(&session).Error
+	return &session, err
+}
+
+// ListActiveSessions возвращает активные сессии пользователя, начиная с последних использованных
+// Сессия активна, если она не отозвана и у нее есть действующий refresh-токен
+func (r *TokenRepository) ListActiveSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Where("EXISTS (SELECT 1 FROM refresh_tokens rt WHERE rt.session_id = sessions.id AND rt.used_at IS NULL AND rt.revoked_at IS NULL AND rt.expires_at > ?)", time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// TouchSession обновляет время последней активности и IP сессии
+// Запись выполняется не чаще раза в interval, чтобы не писать в БД на каждый запрос
+func (r *TokenRepository) TouchSession(id string, ip string, interval time.Duration) error {
+	now := time.Now()
+	return r.db.Model(&models.Session{}).
+		Where("id = ? AND last_seen_at < ?", id, now.Add(-interval)).
+		Updates(map[string]interface{}{"last_seen_at": now, "ip": ip}).Error
+}
+
+// UpdateSessionClient сохраняет данные клиента, обновившего токены сессии
+func (r *TokenRepository) UpdateSessionClient(id string, client models.ClientInfo, device string) error {
+	return r.db.Model(&models.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"last_seen_at": time.Now(),
+			"ip":           client.IP,
+			"user_agent":   client.UserAgent,
+			"device":       device,
+		}).Error
+}
+
+// GetRefreshTokenByHash находит refresh-токен по хешу
+// Возвращает в том числе использованные и отозванные токены
+func (r *TokenRepository) GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	return &token, err
+}
+
+// RotateRefreshToken помечает старый токен использованным и сохраняет новый
+// Возвращает false если старый токен уже был использован параллельным запросом
+func (r *TokenRepository) RotateRefreshToken(oldID uint, newToken *models.RefreshToken) (bool, error) {
+	rotated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", oldID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		rotated = true
+		return tx.Create(newToken).Error
+	})
+	return rotated, err
+}
+
+// RevokeSession отзывает сессию и все ее refresh-токены
+func (r *TokenRepository) RevokeSession(id string, reason string) error {
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Updates(map[string]interface{}{"revoked_at": now, "revoke_reason": reason}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("session_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error
+	})
+}
+
+// RevokeUserSession отзывает сессию, только если она принадлежит пользователю
+// Возвращает false если активная сессия не найдена
+func (r *TokenRepository) RevokeUserSession(userID uint, id string, reason string) (bool, error) {
+	now := time.Now()
+	revoked := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+			Updates(map[string]interface{}{"revoked_at": now, "revoke_reason": reason})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		revoked = true
+		return tx.Model(&models.RefreshToken{}).
+			Where("session_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error
+	})
+	return revoked, err
+}
+
+// RevokeUserSessions отзывает все активные сессии пользователя
+// Access-токены этих сессий перестают приниматься сразу
+func (r *TokenRepository) RevokeUserSessions(userID uint, reason string) error {
+	return r.RevokeOtherUserSessions(userID, "", reason)
+}
+
+// RevokeOtherUserSessions отзывает все активные сессии пользователя, кроме exceptID
+// Пустой exceptID отзывает все сессии
+func (r *TokenRepository) RevokeOtherUserSessions(userID uint, exceptID string, reason string) error {
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+			Updates(map[string]interface{}{"revoked_at": now, "revoke_reason": reason}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND session_id <> ? AND revoked_at IS NULL", userID, exceptID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// RevokeAccessToken добавляет jti access-токена в список отозванных
+// Повторный отзыв того же токена не считается ошибкой
+func (r *TokenRepository) RevokeAccessToken(token *models.RevokedToken) error {
+	return r.db.Where(models.RevokedToken{JTI: token.JTI}).FirstOrCreate(token).Error
+}
+
+// IsAccessTokenRevoked проверяет, отозван ли access-токен
+// Токен считается отозванным, если отозван его jti или вся сессия
+func (r *TokenRepository) IsAccessTokenRevoked(jti string, sessionID string) (bool, error) {
+	revoked, err := r.IsJTIRevoked(jti)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	var count int64
+	err = r.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Count(&count).Error
+	return count == 0, err
+}
+
+// IsJTIRevoked проверяет, отозван ли access-токен с указанным jti
+func (r *TokenRepository) IsJTIRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteExpired удаляет просроченные записи об отозванных access-токенах
+func (r *TokenRepository) DeleteExpired() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error
+}
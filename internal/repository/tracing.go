@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+
+	"go-crud-api/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// tracingSpanKey - ключ, под которым в запросе GORM хранится его span
+const tracingSpanKey = "tracing:span"
+
+// TracingPlugin создает span для каждого запроса к БД
+// Span становится дочерним к span из контекста запроса (WithContext), поэтому
+// в трассировке видно, какой метод сервиса выполнил запрос. Значения параметров не записываются
+type TracingPlugin struct{}
+
+// Name возвращает имя плагина для GORM
+func (TracingPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize регистрирует span вокруг запросов GORM
+func (TracingPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	processors := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register},
+		{"query", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register},
+		{"update", callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register},
+		{"delete", callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register},
+		{"row", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	}
+	for _, processor := range processors {
+		if err := processor.before("tracing:before_"+processor.operation, startQuerySpan(processor.operation)); err != nil {
+			return err
+		}
+		if err := processor.after("tracing:after_"+processor.operation, endQuerySpan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startQuerySpan начинает span запроса
+func startQuerySpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		attrs := []attribute.KeyValue{semconv.DBSystemPostgreSQL, semconv.DBOperation(operation)}
+		if db.Statement.Table != "" {
+			attrs = append(attrs, semconv.DBSQLTable(db.Statement.Table))
+		}
+		_, span := tracing.Start(db.Statement.Context, "gorm."+operation, attrs...)
+		db.InstanceSet(tracingSpanKey, span)
+	}
+}
+
+// endQuerySpan записывает в span текст запроса, число строк и ошибку
+func endQuerySpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(tracingSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBStatement(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		tracing.RecordError(span, db.Error)
+	}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// TwoFactorRepository отвечает за хранение данных двухфакторной аутентификации:
+// кодов восстановления и незавершенных входов
+type TwoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *TwoFactorRepository) WithContext(ctx context.Context) *TwoFactorRepository {
+	return &TwoFactorRepository{db: withContext(r.db, ctx)}
+}
+
+// SetSecret сохраняет новый (еще не подтвержденный) секрет TOTP пользователя
+// 2FA остается выключенной до подтверждения первым кодом
+func (r *TwoFactorRepository) SetSecret(userID uint, secret string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled_at": nil, "totp_last_step": 0}).Error
+}
+
+// ReplaceSecret заменяет сохраненный секрет TOTP, не меняя состояние 2FA
+// Замена выполняется, только если в БД все еще хранится old.
+// Возвращает false, если секрет успели изменить
+func (r *TwoFactorRepository) ReplaceSecret(userID uint, old, secret string) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_secret = ?", userID, old).
+		Update("totp_secret", secret)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListPlaintextSecrets возвращает пользователей, секрет TOTP которых сохранен до появления шифрования
+// Загружаются только ID и секрет
+func (r *TwoFactorRepository) ListPlaintextSecrets() ([]models.User, error) {
+	var users []models.User
+	err := r.db.Model(&models.User{}).
+		Select("id", "totp_secret").
+		Where("totp_secret <> '' AND totp_secret NOT LIKE ?", utils.EncryptedSecretPrefix+"%").
+		Find(&users).Error
+	return users, err
+}
+
+// Enable включает 2FA и заменяет коды восстановления пользователя в одной транзакции
+func (r *TwoFactorRepository) Enable(userID uint, step int64, codes []models.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{"totp_enabled_at": time.Now(), "totp_last_step": step}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+// Disable выключает 2FA и удаляет коды восстановления пользователя
+func (r *TwoFactorRepository) Disable(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{"totp_secret": "", "totp_enabled_at": nil, "totp_last_step": 0}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// ReplaceRecoveryCodes заменяет все коды восстановления пользователя новыми
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID uint, codes []models.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+// UseTOTPStep запоминает последний принятый шаг TOTP
+// Возвращает false если этот или более поздний шаг уже использован (повтор кода)
+func (r *TwoFactorRepository) UseTOTPStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UseRecoveryCode помечает код восстановления использованным
+// Возвращает false если код не найден или уже использован
+func (r *TwoFactorRepository) UseRecoveryCode(userID uint, hash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CreateChallenge сохраняет новый незавершенный вход
+func (r *TwoFactorRepository) CreateChallenge(challenge *models.TwoFactorChallenge) error {
+	return r.db.Create(challenge).Error
+}
+
+// GetChallengeByHash находит незавершенный вход по хешу токена
+func (r *TwoFactorRepository) GetChallengeByHash(hash string) (*models.TwoFactorChallenge, error) {
+	var challenge models.TwoFactorChallenge
+	err := r.db.Where("token_hash = ?", hash).First(&challenge).Error
+	return &challenge, err
+}
+
+// RegisterAttempt увеличивает счетчик попыток ввода кода
+// Возвращает false если лимит попыток уже исчерпан или вход завершен
+func (r *TwoFactorRepository) RegisterAttempt(id uint, maxAttempts int) (bool, error) {
+	result := r.db.Model(&models.TwoFactorChallenge{}).
+		Where("id = ? AND used_at IS NULL AND attempts < ?", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// CompleteChallenge помечает вход завершенным
+// Возвращает false если токен уже был обменян
+func (r *TwoFactorRepository) CompleteChallenge(id uint) (bool, error) {
+	result := r.db.Model(&models.TwoFactorChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// replaceRecoveryCodes удаляет старые коды восстановления и сохраняет новые
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codes []models.RecoveryCode) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&codes).Error
+}
@@ -1,12 +1,18 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"go-crud-api/internal/models"
+	"go-crud-api/internal/utils"
 
 	"gorm.io/gorm"
 )
 
 // UserRepository отвечает за работу с данными пользователей в БД
+// Пользователи принадлежат организациям: запросы выполняются через ForOrg или System,
+// без них TenantPlugin отклоняет запрос
 type UserRepository struct {
 	db *gorm.DB
 }
@@ -15,6 +21,34 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// WithContext возвращает репозиторий, выполняющий запросы в контексте ctx
+func (r *UserRepository) WithContext(ctx context.Context) *UserRepository {
+	return &UserRepository{db: withContext(r.db, ctx)}
+}
+
+// WithTx возвращает репозиторий, работающий в рамках транзакции tx
+// Организация, выбранная ForOrg или System, сохраняется
+func (r *UserRepository) WithTx(tx *gorm.DB) *UserRepository {
+	return &UserRepository{db: withTx(r.db, tx)}
+}
+
+// Transaction выполняет fn в транзакции БД
+// Транзакцию можно передать в другие репозитории через их метод WithTx
+func (r *UserRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
+// ForOrg возвращает репозиторий, работающий только с пользователями организации orgID
+func (r *UserRepository) ForOrg(orgID uint) *UserRepository {
+	return &UserRepository{db: WithOrg(r.db, orgID)}
+}
+
+// System возвращает репозиторий для операций системного уровня, не ограниченных организацией
+// Используется при входе, обновлении токенов и других операциях, где пользователь известен по email или ID из токена
+func (r *UserRepository) System() *UserRepository {
+	return &UserRepository{db: WithoutTenant(r.db)}
+}
+
 // Create сохраняет нового пользователя в БД
 func (r *UserRepository) Create(user *models.User) error {
 	return r.db.Create(user).Error
@@ -36,10 +70,17 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	return &user, err
 }
 
-// Update обновляет данные пользователя
-// Обновляет только непустые поля
+// Update сохраняет все поля пользователя
+// В отличие от Save не создает запись, если пользователя нет в организации
 func (r *UserRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+	result := r.db.Select("*").Omit("Orders").Updates(user)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 // Delete удаляет пользователя по ID
@@ -53,15 +94,7 @@ func (r *UserRepository) List(params models.PaginationParams) ([]models.User, in
 	var users []models.User
 	var total int64
 
-	query := r.db.Model(&models.User{})
-
-	// Применяем фильтры
-	if params.MinAge > 0 {
-		query = query.Where("age >= ?", params.MinAge)
-	}
-	if params.MaxAge > 0 {
-		query = query.Where("age <= ?", params.MaxAge)
-	}
+	query := applyUserFilters(r.db.Model(&models.User{}), params)
 
 	// Получаем общее количество записей
 	if err := query.Count(&total).Error; err != nil {
@@ -77,20 +110,55 @@ func (r *UserRepository) List(params models.PaginationParams) ([]models.User, in
 	return users, total, nil
 }
 
+// ListAfter возвращает до limit пользователей, созданных раньше позиции курсора
+// Использует keyset-пагинацию по (created_at, id) без OFFSET и COUNT(*).
+// Если after == nil, возвращается первая страница
+func (r *UserRepository) ListAfter(params models.PaginationParams, after *utils.Cursor, limit int) ([]models.User, error) {
+	var users []models.User
+
+	query := applyUserFilters(r.db.Model(&models.User{}), params)
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
+	}
+
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+// applyUserFilters добавляет в запрос фильтры списка пользователей
+func applyUserFilters(query *gorm.DB, params models.PaginationParams) *gorm.DB {
+	if params.MinAge > 0 {
+		query = query.Where("age >= ?", params.MinAge)
+	}
+	if params.MaxAge > 0 {
+		query = query.Where("age <= ?", params.MaxAge)
+	}
+	return query
+}
+
 // CheckExists проверяет существование пользователя по email
-// Используется при регистрации для проверки уникальности email
+// Используется при регистрации для проверки уникальности email.
+// Email уникален во всех организациях, поэтому проверка не ограничена организацией
 func (r *UserRepository) CheckExists(email string) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.User{}).Unscoped().Where("email = ? AND deleted_at IS NULL", email).Count(&count).Error
+	err := WithoutTenant(r.db).Model(&models.User{}).Unscoped().Where("email = ? AND deleted_at IS NULL", email).Count(&count).Error
 	return count > 0, err
 }
 
-func (r *UserRepository) CreateOrder(order *models.Order) error {
-	return r.db.Create(order).Error
-}
-
 func (r *UserRepository) GetUserOrders(userID uint) ([]models.Order, error) {
 	var orders []models.Order
-	err := r.db.Where("user_id = ?", userID).Find(&orders).Error
+	err := r.db.Preload("Items").Where("user_id = ?", userID).Find(&orders).Error
 	return orders, err
 }
+
+// SetLockedUntil задает время окончания блокировки входа
+// nil снимает блокировку
+func (r *UserRepository) SetLockedUntil(id uint, until *time.Time) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("locked_until", until).Error
+}
+
+// UpdatePasswordHash заменяет хеш пароля, не трогая остальные поля
+// Используется для пересчета хеша с новыми параметрами
+func (r *UserRepository) UpdatePasswordHash(id uint, hash string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("password_hash", hash).Error
+}
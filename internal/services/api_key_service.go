@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKey       = errors.New("invalid api key")
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrInvalidAPIKeyExpiry = errors.New("expires_at must be in the future")
+)
+
+// apiKeyTouchInterval - как часто обновляется время последнего использования ключа
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyService отвечает за персональные ключи API
+// Ключ имеет вид gca_<prefix>_<secret>: prefix хранится открыто для поиска, от ключа целиком хранится хеш
+type APIKeyService struct {
+	apiKeyRepo *repository.APIKeyRepository
+	userRepo   *repository.UserRepository
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+func NewAPIKeyService(apiKeyRepo *repository.APIKeyRepository, userRepo *repository.UserRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *APIKeyService) WithContext(ctx context.Context) *APIKeyService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.apiKeyRepo = s.apiKeyRepo.WithContext(ctx)
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	return &scoped
+}
+
+// Create выпускает новый ключ API для пользователя
+// Ключ целиком возвращается только в ответе на создание
+func (s *APIKeyService) Create(userID uint, req models.CreateAPIKeyRequest) (*models.APIKeyCreatedResponse, error) {
+	s, span := startSpan(s, s.ctx, "APIKeyService.Create")
+	defer span.End()
+
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, errors.New("user not found")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+
+	prefixBytes := make([]byte, 6)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return nil, err
+	}
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := hex.EncodeToString(prefixBytes)
+	rawKey := models.APIKeyPrefix + prefix + "_" + secret
+
+	scopes := req.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	key := models.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashToken(rawKey),
+		Scopes:    pq.StringArray(scopes),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.apiKeyRepo.Create(&key); err != nil {
+		return nil, err
+	}
+
+	utils.LogOperation(s.ctx, "CreateAPIKey", userID, "API key "+prefix+" created")
+	return &models.APIKeyCreatedResponse{APIKey: key, Key: rawKey}, nil
+}
+
+// List возвращает ключи пользователя без секретов
+func (s *APIKeyService) List(userID uint) ([]models.APIKey, error) {
+	s, span := startSpan(s, s.ctx, "APIKeyService.List")
+	defer span.End()
+
+	return s.apiKeyRepo.ListByUser(userID)
+}
+
+// Revoke отзывает ключ пользователя
+// Отозванный ключ перестает приниматься сразу
+func (s *APIKeyService) Revoke(userID, keyID uint) error {
+	s, span := startSpan(s, s.ctx, "APIKeyService.Revoke")
+	defer span.End()
+
+	revoked, err := s.apiKeyRepo.Revoke(userID, keyID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	utils.LogOperation(s.ctx, "RevokeAPIKey", userID, "API key revoked")
+	return nil
+}
+
+// Authenticate проверяет ключ API и возвращает ключ и его владельца
+// Отклоняет отозванные и просроченные ключи, а также ключи удаленных пользователей
+func (s *APIKeyService) Authenticate(rawKey, ip string) (*models.APIKey, *models.User, error) {
+	s, span := startSpan(s, s.ctx, "APIKeyService.Authenticate")
+	defer span.End()
+
+	rest, ok := strings.CutPrefix(rawKey, models.APIKeyPrefix)
+	if !ok {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	prefix, _, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetByPrefix(prefix)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(utils.HashToken(rawKey))) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetByID(key.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if err := s.apiKeyRepo.TouchLastUsed(key.ID, ip, apiKeyTouchInterval); err != nil {
+		utils.LogError(s.ctx, "APIKeyLastUsed", err)
+	}
+
+	return key, user, nil
+}
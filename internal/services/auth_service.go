@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrScopeNotGranted     = errors.New("scope is not granted to the current token")
+)
+
+// sessionTouchInterval - как часто обновляется время последней активности сессии
+const sessionTouchInterval = time.Minute
+
+// AuthService отвечает за выдачу, обновление и отзыв токенов
+// Access-токены короткоживущие, refresh-токены ротируются при каждом обновлении
+type AuthService struct {
+	tokenRepo         *repository.TokenRepository
+	userRepo          *repository.UserRepository
+	impersonationRepo *repository.ImpersonationRepository
+	jwt               *utils.JWTManager
+	cfg               config.JWTConfig
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+func NewAuthService(tokenRepo *repository.TokenRepository, userRepo *repository.UserRepository, impersonationRepo *repository.ImpersonationRepository, jwtManager *utils.JWTManager, cfg config.JWTConfig) *AuthService {
+	return &AuthService{
+		tokenRepo:         tokenRepo,
+		userRepo:          userRepo,
+		impersonationRepo: impersonationRepo,
+		jwt:               jwtManager,
+		cfg:               cfg,
+	}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *AuthService) WithContext(ctx context.Context) *AuthService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.tokenRepo = s.tokenRepo.WithContext(ctx)
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	scoped.impersonationRepo = s.impersonationRepo.WithContext(ctx)
+	return &scoped
+}
+
+// IssueTokens создает новую сессию и выдает пару токенов
+// Вызывается после успешной аутентификации пользователя, client сохраняется в сессии
+func (s *AuthService) IssueTokens(user *models.User, client models.ClientInfo) (*models.TokenResponse, error) {
+	s, span := startSpan(s, s.ctx, "AuthService.IssueTokens")
+	defer span.End()
+
+	sessionID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	rawRefresh, refreshToken, err := s.newRefreshToken(user.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		Device:     utils.DescribeUserAgent(client.UserAgent),
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		LastSeenAt: time.Now(),
+	}
+	if err := s.tokenRepo.CreateSession(session, refreshToken); err != nil {
+		return nil, err
+	}
+
+	return s.buildResponse(user, sessionID, models.AllScopes, rawRefresh)
+}
+
+// Exchange обменивает текущий access-токен на пару токенов с меньшими правами
+// Открывает отдельную сессию, refresh-токены которой сохраняют запрошенные области.
+// Области должны входить в области текущего токена
+func (s *AuthService) Exchange(claims *utils.Claims, scopes []string, client models.ClientInfo) (*models.TokenResponse, error) {
+	s, span := startSpan(s, s.ctx, "AuthService.Exchange")
+	defer span.End()
+
+	granted := claims.Scopes()
+	if len(granted) == 0 {
+		granted = models.AllScopes
+	}
+	for _, scope := range scopes {
+		if !containsScope(granted, scope) {
+			return nil, fmt.Errorf("%w: %s", ErrScopeNotGranted, scope)
+		}
+	}
+
+	// Роль берем актуальную, а не из токена
+	user, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	rawRefresh, refreshToken, err := s.newRefreshToken(user.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		Device:     utils.DescribeUserAgent(client.UserAgent),
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		Scopes:     scopes,
+		LastSeenAt: time.Now(),
+	}
+	if err := s.tokenRepo.CreateSession(session, refreshToken); err != nil {
+		return nil, err
+	}
+
+	utils.LogOperation(s.ctx, "TokenExchange", user.ID, "Session "+sessionID+" opened with scopes "+strings.Join(scopes, " "))
+	return s.buildResponse(user, sessionID, scopes, rawRefresh)
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов
+// Повторное использование уже ротированного токена отзывает всю сессию
+func (s *AuthService) Refresh(rawToken string, client models.ClientInfo) (*models.TokenResponse, error) {
+	s, span := startSpan(s, s.ctx, "AuthService.Refresh")
+	defer span.End()
+
+	token, err := s.tokenRepo.GetRefreshTokenByHash(utils.HashToken(rawToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if token.UsedAt != nil {
+		return nil, s.handleReuse(token)
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.tokenRepo.GetSession(token.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Проверяем, что пользователь не был удален, и получаем актуальную роль
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	rawRefresh, newToken, err := s.newRefreshToken(token.UserID, token.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.tokenRepo.RotateRefreshToken(token.ID, newToken)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Токен успели использовать параллельно - считаем это повторным использованием
+		return nil, s.handleReuse(token)
+	}
+
+	if err := s.tokenRepo.UpdateSessionClient(token.SessionID, client, utils.DescribeUserAgent(client.UserAgent)); err != nil {
+		utils.LogError(s.ctx, "Refresh", err)
+	}
+
+	return s.buildResponse(user, token.SessionID, sessionScopes(session), rawRefresh)
+}
+
+// Logout завершает сессию текущего access-токена
+// Отзывает сам access-токен и все refresh-токены сессии.
+// Для токена входа от имени пользователя завершает эту сессию в журнале
+func (s *AuthService) Logout(claims *utils.Claims) error {
+	s, span := startSpan(s, s.ctx, "AuthService.Logout")
+	defer span.End()
+
+	revoked := &models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if err := s.tokenRepo.RevokeAccessToken(revoked); err != nil {
+		return err
+	}
+
+	if claims.Actor != nil {
+		if _, err := s.impersonationRepo.EndSession(claims.SessionID); err != nil {
+			return err
+		}
+		utils.LogOperation(s.ctx, "ImpersonationEnded", claims.UserID, fmt.Sprintf("Ended by user %d, session %s", claims.Actor.UserID, claims.SessionID))
+	} else if err := s.tokenRepo.RevokeSession(claims.SessionID, "logout"); err != nil {
+		return err
+	}
+
+	// Заодно чистим записи, которые больше не нужны
+	if err := s.tokenRepo.DeleteExpired(); err != nil {
+		utils.LogError(s.ctx, "Logout", err)
+	}
+	return nil
+}
+
+// ValidateAccessToken разбирает access-токен и проверяет, не отозван ли он
+func (s *AuthService) ValidateAccessToken(tokenString string) (*utils.Claims, error) {
+	s, span := startSpan(s, s.ctx, "AuthService.ValidateAccessToken")
+	defer span.End()
+
+	claims, err := s.jwt.ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Токен входа от имени пользователя действует, пока не завершена его сессия в журнале
+	if claims.Actor != nil {
+		revoked, err := s.tokenRepo.IsJTIRevoked(claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		active, err := s.impersonationRepo.IsActive(claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked || !active {
+			return nil, ErrTokenRevoked
+		}
+		return claims, nil
+	}
+
+	revoked, err := s.tokenRepo.IsAccessTokenRevoked(claims.ID, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// ListSessions возвращает активные сессии пользователя
+// currentSessionID отмечает сессию, из которой выполнен запрос
+func (s *AuthService) ListSessions(userID uint, currentSessionID string) ([]models.SessionResponse, error) {
+	s, span := startSpan(s, s.ctx, "AuthService.ListSessions")
+	defer span.End()
+
+	sessions, err := s.tokenRepo.ListActiveSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, models.SessionResponse{
+			ID:         session.ID,
+			Device:     session.Device,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			Scopes:     session.Scopes,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+	return response, nil
+}
+
+// RevokeSession завершает сессию пользователя
+// Access-токены сессии перестают приниматься со следующего запроса
+func (s *AuthService) RevokeSession(userID uint, sessionID string) error {
+	s, span := startSpan(s, s.ctx, "AuthService.RevokeSession")
+	defer span.End()
+
+	revoked, err := s.tokenRepo.RevokeUserSession(userID, sessionID, "revoked_by_user")
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+
+	utils.LogOperation(s.ctx, "RevokeSession", userID, "Session "+sessionID+" revoked")
+	return nil
+}
+
+// RevokeOtherSessions завершает все сессии пользователя, кроме currentSessionID
+// Пустой currentSessionID завершает все сессии
+func (s *AuthService) RevokeOtherSessions(userID uint, currentSessionID string) error {
+	s, span := startSpan(s, s.ctx, "AuthService.RevokeOtherSessions")
+	defer span.End()
+
+	if err := s.tokenRepo.RevokeOtherUserSessions(userID, currentSessionID, "revoked_by_user"); err != nil {
+		return err
+	}
+
+	utils.LogOperation(s.ctx, "RevokeOtherSessions", userID, "Other sessions revoked")
+	return nil
+}
+
+// TouchSession отмечает активность сессии
+func (s *AuthService) TouchSession(sessionID string, ip string) error {
+	s, span := startSpan(s, s.ctx, "AuthService.TouchSession")
+	defer span.End()
+
+	return s.tokenRepo.TouchSession(sessionID, ip, sessionTouchInterval)
+}
+
+// JWKS возвращает открытые ключи, которыми можно проверить access-токены
+func (s *AuthService) JWKS() utils.JWKSet {
+	return s.jwt.JWKS()
+}
+
+// handleReuse отзывает всю сессию при повторном использовании refresh-токена
+func (s *AuthService) handleReuse(token *models.RefreshToken) error {
+	utils.LogOperation(s.ctx, "RefreshTokenReuse", token.UserID, "Session "+token.SessionID+" revoked")
+	if err := s.tokenRepo.RevokeSession(token.SessionID, "refresh_token_reuse"); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// newRefreshToken генерирует refresh-токен и запись для его хранения
+func (s *AuthService) newRefreshToken(userID uint, sessionID string) (string, *models.RefreshToken, error) {
+	raw, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return raw, &models.RefreshToken{
+		SessionID: sessionID,
+		UserID:    userID,
+		TokenHash: utils.HashToken(raw),
+		ExpiresAt: time.Now().Add(s.cfg.RefreshTTL),
+	}, nil
+}
+
+// buildResponse выпускает access-токен и формирует ответ с парой токенов
+func (s *AuthService) buildResponse(user *models.User, sessionID string, scopes []string, rawRefresh string) (*models.TokenResponse, error) {
+	accessToken, _, err := s.jwt.GenerateToken(user.ID, user.OrgID, user.Role, sessionID, scopes, s.cfg.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: rawRefresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.cfg.AccessTTL.Seconds()),
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// sessionScopes возвращает области доступа токенов сессии
+// Сессия обычного входа получает все области
+func sessionScopes(session *models.Session) []string {
+	if len(session.Scopes) == 0 {
+		return models.AllScopes
+	}
+	return session.Scopes
+}
+
+// containsScope проверяет, входит ли область в список
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/mail"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	ErrEmailNotVerified         = errors.New("email is not verified")
+)
+
+// EmailVerificationService отвечает за подтверждение email пользователей
+// Письмо со ссылкой отправляется при регистрации и при смене email
+type EmailVerificationService struct {
+	userRepo   *repository.UserRepository
+	verifyRepo *repository.EmailVerificationRepository
+	mailer     mail.Sender
+	cfg        config.AuthConfig
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+func NewEmailVerificationService(userRepo *repository.UserRepository, verifyRepo *repository.EmailVerificationRepository, mailer mail.Sender, cfg config.AuthConfig) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:   userRepo,
+		verifyRepo: verifyRepo,
+		mailer:     mailer,
+		cfg:        cfg,
+	}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *EmailVerificationService) WithContext(ctx context.Context) *EmailVerificationService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	scoped.verifyRepo = s.verifyRepo.WithContext(ctx)
+	return &scoped
+}
+
+// SendVerification отправляет пользователю ссылку для подтверждения текущего email
+// Ранее отправленные ссылки перестают действовать
+func (s *EmailVerificationService) SendVerification(userID uint) error {
+	s, span := startSpan(s, s.ctx, "EmailVerificationService.SendVerification")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	if err := s.verifyRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+
+	rawToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	token := &models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: utils.HashToken(rawToken),
+		ExpiresAt: time.Now().Add(s.cfg.EmailVerificationTTL),
+	}
+	if err := s.verifyRepo.Create(token); err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.cfg.PublicURL, "/") + "/verify-email?token=" + url.QueryEscape(rawToken)
+	utils.LogOperation(s.ctx, "EmailVerificationSent", user.ID, "Verification link sent")
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Подтверждение email",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nПодтвердите адрес электронной почты, перейдя по ссылке:\n%s\n\n"+
+			"Ссылка действует %s.", user.Name, link, s.cfg.EmailVerificationTTL),
+	})
+}
+
+// ResendByEmail повторно отправляет письмо с подтверждением
+// Для неизвестного или уже подтвержденного адреса ничего не делает,
+// чтобы по ответу нельзя было определить, зарегистрирован ли адрес
+func (s *EmailVerificationService) ResendByEmail(email string) error {
+	s, span := startSpan(s, s.ctx, "EmailVerificationService.ResendByEmail")
+	defer span.End()
+
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.SendVerification(user.ID)
+}
+
+// Verify подтверждает email по токену из письма
+// Токен недействителен, если пользователь успел сменить адрес после отправки письма
+func (s *EmailVerificationService) Verify(rawToken string) error {
+	s, span := startSpan(s, s.ctx, "EmailVerificationService.Verify")
+	defer span.End()
+
+	token, err := s.verifyRepo.GetByHash(utils.HashToken(rawToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil || user.Email != token.Email {
+		return ErrInvalidVerificationToken
+	}
+
+	used, err := s.verifyRepo.MarkUsed(token.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidVerificationToken
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	utils.LogOperation(s.ctx, "EmailVerified", user.ID, "Email "+user.Email+" verified")
+	return nil
+}
+
+// CheckLogin проверяет, может ли пользователь войти с текущим статусом email
+func (s *EmailVerificationService) CheckLogin(user *models.User) error {
+	if !s.cfg.AllowUnverifiedLogin && user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
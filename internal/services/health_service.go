@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/utils"
+)
+
+// readinessCheckTimeout - сколько ждать ответа БД при проверке готовности
+const readinessCheckTimeout = 2 * time.Second
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// HealthService проверяет, может ли приложение обслуживать запросы
+type HealthService struct {
+	db *sql.DB
+
+	// migrated запоминает, что все миграции применены: откатить их без перезапуска нельзя
+	migrated     atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(db *sql.DB) *HealthService {
+	return &HealthService{db: db}
+}
+
+// MarkShuttingDown переводит приложение в состояние "не готово"
+// Вызывается в начале остановки, чтобы балансировщик перестал присылать новые запросы
+func (s *HealthService) MarkShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Readiness проверяет зависимости приложения: соединение с БД и примененные миграции
+// Возвращает false, если хотя бы одна проверка не прошла
+func (s *HealthService) Readiness(ctx context.Context) (*models.HealthResponse, bool) {
+	checks := map[string]string{}
+	ready := true
+
+	record := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = models.HealthStatusOK
+	}
+
+	if s.shuttingDown.Load() {
+		record("server", errShuttingDown)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	dbErr := s.db.PingContext(ctx)
+	record("database", dbErr)
+	if dbErr == nil {
+		record("migrations", s.checkMigrations())
+	}
+
+	status := models.HealthStatusOK
+	if !ready {
+		status = models.HealthStatusFail
+	}
+	return &models.HealthResponse{Status: status, Checks: checks}, ready
+}
+
+// checkMigrations проверяет, что к БД применены все миграции из каталога migrations
+func (s *HealthService) checkMigrations() error {
+	if s.migrated.Load() {
+		return nil
+	}
+
+	pending, err := utils.PendingMigrations(s.db)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations are not applied", pending)
+	}
+
+	s.migrated.Store(true)
+	return nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB открывает БД SQLite в памяти с TenantPlugin и таблицами моделей models
+// Организация по умолчанию создается всегда
+func newTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	// Каждое соединение с :memory: открывает отдельную БД
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(append([]interface{}{&models.Organization{}, &models.User{}}, tables...)...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&models.Organization{ID: models.DefaultOrgID, Name: "Default", Slug: "default"}).Error; err != nil {
+		t.Fatalf("create default organization: %v", err)
+	}
+	if err := db.Use(repository.TenantPlugin{}); err != nil {
+		t.Fatalf("register tenant plugin: %v", err)
+	}
+	return db
+}
+
+// newTestPasswordService создает сервис паролей с быстрым bcrypt и без требований к паролю
+func newTestPasswordService(t *testing.T, db *gorm.DB) *PasswordService {
+	t.Helper()
+
+	passwords, err := NewPasswordService(repository.NewPasswordHistoryRepository(db), config.PasswordConfig{
+		Algorithm:   "bcrypt",
+		BcryptCost:  bcrypt.MinCost,
+		MinLength:   1,
+		MaxLength:   72,
+		HistorySize: 3,
+	})
+	if err != nil {
+		t.Fatalf("create password service: %v", err)
+	}
+	return passwords
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIdempotencyKeyInUse    = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key was already used with a different request")
+)
+
+// StoredResponse представляет ответ, сохраненный для повторных запросов
+type StoredResponse struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+}
+
+// IdempotencyService обеспечивает однократное выполнение запросов
+// с одинаковым заголовком Idempotency-Key
+type IdempotencyService struct {
+	repo *repository.IdempotencyRepository
+	ttl  time.Duration
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+func NewIdempotencyService(repo *repository.IdempotencyRepository, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{repo: repo, ttl: ttl}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *IdempotencyService) WithContext(ctx context.Context) *IdempotencyService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.repo = s.repo.WithContext(ctx)
+	return &scoped
+}
+
+// Begin регистрирует начало выполнения запроса с ключом
+// Если запрос с тем же ключом уже выполнен, возвращает сохраненный ответ.
+// Если ключ использовался с другим запросом, возвращает ErrIdempotencyKeyMismatch,
+// если первый запрос еще выполняется - ErrIdempotencyKeyInUse
+func (s *IdempotencyService) Begin(userID uint, key, method, path, requestHash string) (*models.IdempotencyKey, *StoredResponse, error) {
+	s, span := startSpan(s, s.ctx, "IdempotencyService.Begin")
+	defer span.End()
+
+	record := &models.IdempotencyKey{
+		UserID:          userID,
+		Key:             key,
+		Method:          method,
+		Path:            path,
+		RequestHash:     requestHash,
+		ResponseHeaders: "{}",
+		ExpiresAt:       time.Now().Add(s.ttl),
+	}
+
+	reserved, err := s.repo.Reserve(record)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reserved {
+		return record, nil, nil
+	}
+
+	existing, err := s.repo.GetByKey(userID, key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Запись успели удалить - предлагаем клиенту повторить запрос
+			return nil, nil, ErrIdempotencyKeyInUse
+		}
+		return nil, nil, err
+	}
+
+	// Просроченный ключ можно использовать заново
+	if time.Now().After(existing.ExpiresAt) {
+		if err := s.repo.Delete(existing.ID); err != nil {
+			return nil, nil, err
+		}
+		return s.Begin(userID, key, method, path, requestHash)
+	}
+
+	if existing.Method != method || existing.Path != path || existing.RequestHash != requestHash {
+		return nil, nil, ErrIdempotencyKeyMismatch
+	}
+	if existing.StatusCode == nil {
+		return nil, nil, ErrIdempotencyKeyInUse
+	}
+
+	headers := http.Header{}
+	if err := json.Unmarshal([]byte(existing.ResponseHeaders), &headers); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, &StoredResponse{
+		StatusCode: *existing.StatusCode,
+		Headers:    headers,
+		Body:       existing.ResponseBody,
+	}, nil
+}
+
+// Complete сохраняет ответ на запрос для последующих повторов
+func (s *IdempotencyService) Complete(record *models.IdempotencyKey, response *StoredResponse) error {
+	s, span := startSpan(s, s.ctx, "IdempotencyService.Complete")
+	defer span.End()
+
+	headers, err := json.Marshal(response.Headers)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Complete(record.ID, response.StatusCode, string(headers), response.Body); err != nil {
+		return err
+	}
+
+	// Заодно чистим записи, которые больше не нужны
+	if err := s.repo.DeleteExpired(); err != nil {
+		utils.LogError(s.ctx, "IdempotencyCleanup", err)
+	}
+	return nil
+}
+
+// Abort освобождает ключ, если ответ не должен сохраняться
+// (например, при ошибке сервера клиент может повторить запрос)
+func (s *IdempotencyService) Abort(record *models.IdempotencyKey) error {
+	s, span := startSpan(s, s.ctx, "IdempotencyService.Abort")
+	defer span.End()
+
+	return s.repo.Delete(record.ID)
+}
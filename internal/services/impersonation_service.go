@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrImpersonationNotAllowed = errors.New("impersonation of this user is not allowed")
+	ErrImpersonationNotFound   = errors.New("impersonation session not found")
+	ErrImpersonatedUserMissing = errors.New("user not found")
+)
+
+// defaultImpersonationListLimit - сколько записей журнала возвращается по умолчанию
+const defaultImpersonationListLimit = 50
+
+// ImpersonationService отвечает за вход сотрудников от имени пользователей
+// Каждый вход и каждый запрос в его рамках сохраняются в журнале
+type ImpersonationService struct {
+	impersonationRepo *repository.ImpersonationRepository
+	userRepo          *repository.UserRepository
+	jwt               *utils.JWTManager
+	cfg               config.AuthConfig
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+func NewImpersonationService(impersonationRepo *repository.ImpersonationRepository, userRepo *repository.UserRepository, jwtManager *utils.JWTManager, cfg config.AuthConfig) *ImpersonationService {
+	return &ImpersonationService{
+		impersonationRepo: impersonationRepo,
+		userRepo:          userRepo,
+		jwt:               jwtManager,
+		cfg:               cfg,
+	}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *ImpersonationService) WithContext(ctx context.Context) *ImpersonationService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.impersonationRepo = s.impersonationRepo.WithContext(ctx)
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	return &scoped
+}
+
+// Start выдает сотруднику короткоживущий токен для работы от имени пользователя
+// Войти можно только от имени обычного пользователя своей организации и не от своего имени
+func (s *ImpersonationService) Start(orgID, actorID uint, actorRole string, userID uint, reason string, client models.ClientInfo) (*models.ImpersonationResponse, error) {
+	s, span := startSpan(s, s.ctx, "ImpersonationService.Start")
+	defer span.End()
+
+	user, err := s.userRepo.ForOrg(orgID).GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImpersonatedUserMissing
+		}
+		return nil, err
+	}
+	if user.ID == actorID || user.Role != models.RoleUser {
+		return nil, ErrImpersonationNotAllowed
+	}
+
+	sessionID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.ImpersonationSession{
+		ID:        sessionID,
+		OrgID:     user.OrgID,
+		ActorID:   actorID,
+		UserID:    user.ID,
+		Reason:    reason,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		ExpiresAt: time.Now().Add(s.cfg.ImpersonationTTL),
+	}
+	if err := s.impersonationRepo.ForOrg(orgID).CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	actor := utils.ActorClaims{UserID: actorID, Role: actorRole}
+	token, _, err := s.jwt.GenerateImpersonationToken(user.ID, user.OrgID, user.Role, sessionID, models.ImpersonationScopes, actor, s.cfg.ImpersonationTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.LogOperation(s.ctx, "ImpersonationStarted", user.ID, fmt.Sprintf("Impersonated by user %d, session %s: %s", actorID, sessionID, reason))
+	return &models.ImpersonationResponse{
+		Token:           token,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(s.cfg.ImpersonationTTL.Seconds()),
+		ImpersonationID: sessionID,
+		UserID:          user.ID,
+	}, nil
+}
+
+// RecordEvent сохраняет запрос, выполненный от имени пользователя
+func (s *ImpersonationService) RecordEvent(sessionID, method, path string, status int) error {
+	s, span := startSpan(s, s.ctx, "ImpersonationService.RecordEvent")
+	defer span.End()
+
+	return s.impersonationRepo.CreateEvent(&models.ImpersonationEvent{
+		SessionID: sessionID,
+		Method:    method,
+		Path:      path,
+		Status:    status,
+	})
+}
+
+// List возвращает журнал входов от имени пользователей организации
+func (s *ImpersonationService) List(orgID uint, filter models.ImpersonationFilter) ([]models.ImpersonationSession, error) {
+	s, span := startSpan(s, s.ctx, "ImpersonationService.List")
+	defer span.End()
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultImpersonationListLimit
+	}
+	return s.impersonationRepo.ForOrg(orgID).ListSessions(filter)
+}
+
+// Get возвращает сессию из журнала организации вместе с выполненными запросами
+func (s *ImpersonationService) Get(orgID uint, id string) (*models.ImpersonationDetails, error) {
+	s, span := startSpan(s, s.ctx, "ImpersonationService.Get")
+	defer span.End()
+
+	session, err := s.impersonationRepo.ForOrg(orgID).GetSession(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImpersonationNotFound
+		}
+		return nil, err
+	}
+
+	events, err := s.impersonationRepo.ListEvents(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ImpersonationDetails{ImpersonationSession: *session, Events: events}, nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/metrics"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOIDCDisabled         = errors.New("oidc login is not configured")
+	ErrInvalidOIDCState     = errors.New("invalid or expired oidc state")
+	ErrOIDCLoginFailed      = errors.New("oidc login failed")
+	ErrOIDCAccountNotLinked = errors.New("no account is linked to this identity")
+	ErrOIDCEmailConflict    = errors.New("an account with this email already exists, but the provider did not verify the email")
+)
+
+// oidcClaims содержит данные пользователя из ID-токена
+type oidcClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// OIDCService отвечает за вход через внешнего провайдера OpenID Connect
+// Использует authorization code flow с PKCE. Настройки провайдера загружаются
+// при первом входе, поэтому недоступный провайдер не мешает запуску приложения
+type OIDCService struct {
+	userRepo  *repository.UserRepository
+	oidcRepo  *repository.OIDCRepository
+	passwords *PasswordService
+	cfg       config.OIDCConfig
+	provider  *oidcProvider
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+// oidcProvider хранит клиент провайдера, загруженный при первом входе
+// Хранится по указателю, чтобы копии сервиса из WithContext использовали один клиент
+type oidcProvider struct {
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewOIDCService(userRepo *repository.UserRepository, oidcRepo *repository.OIDCRepository, passwords *PasswordService, cfg config.OIDCConfig) *OIDCService {
+	return &OIDCService{
+		userRepo:  userRepo,
+		oidcRepo:  oidcRepo,
+		passwords: passwords,
+		cfg:       cfg,
+		provider:  &oidcProvider{},
+	}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *OIDCService) WithContext(ctx context.Context) *OIDCService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	scoped.oidcRepo = s.oidcRepo.WithContext(ctx)
+	scoped.passwords = s.passwords.WithContext(ctx)
+	return &scoped
+}
+
+// Begin начинает вход через провайдера
+// Возвращает адрес, на который нужно перенаправить пользователя, и state для проверки на callback
+func (s *OIDCService) Begin() (string, string, error) {
+	s, span := startSpan(s, s.ctx, "OIDCService.Begin")
+	defer span.End()
+
+	oauthConfig, _, err := s.client()
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier := oauth2.GenerateVerifier()
+
+	request := &models.OIDCAuthRequest{
+		StateHash:    utils.HashToken(state),
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(s.cfg.StateTTL),
+	}
+	if err := s.oidcRepo.CreateAuthRequest(request); err != nil {
+		return "", "", err
+	}
+
+	// Заодно чистим записи, которые больше не нужны
+	if err := s.oidcRepo.DeleteExpiredAuthRequests(); err != nil {
+		utils.LogError(s.ctx, "OIDCCleanup", err)
+	}
+
+	authURL := oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(codeVerifier), oidc.Nonce(nonce))
+	return authURL, state, nil
+}
+
+// Complete завершает вход: обменивает code на токены провайдера и проверяет ID-токен
+// Возвращает пользователя, к которому привязана внешняя учетная запись.
+// При первом входе учетная запись привязывается к пользователю с тем же подтвержденным email
+// или, если включено автосоздание, создается новый пользователь
+func (s *OIDCService) Complete(state, code string) (*models.User, error) {
+	s, span := startSpan(s, s.ctx, "OIDCService.Complete")
+	defer span.End()
+
+	oauthConfig, verifier, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := s.oidcRepo.GetAuthRequestByHash(utils.HashToken(state))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	if request.UsedAt != nil || time.Now().After(request.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	used, err := s.oidcRepo.MarkAuthRequestUsed(request.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidOIDCState
+	}
+
+	token, err := oauthConfig.Exchange(s.ctx, code, oauth2.VerifierOption(request.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: code exchange: %v", ErrOIDCLoginFailed, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: no id_token in response", ErrOIDCLoginFailed)
+	}
+	idToken, err := verifier.Verify(s.ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+	if idToken.Nonce != request.Nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCLoginFailed)
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+
+	return s.resolveUser(idToken.Issuer, idToken.Subject, claims)
+}
+
+// resolveUser находит или создает пользователя для внешней учетной записи
+func (s *OIDCService) resolveUser(provider, subject string, claims oidcClaims) (*models.User, error) {
+	identity, err := s.oidcRepo.GetIdentity(provider, subject)
+	if err == nil {
+		user, err := s.userRepo.GetByID(identity.UserID)
+		if err != nil {
+			return nil, ErrOIDCAccountNotLinked
+		}
+		if err := s.oidcRepo.TouchIdentity(identity.ID, claims.Email); err != nil {
+			utils.LogError(s.ctx, "OIDCLogin", err)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, fmt.Errorf("%w: provider did not return an email", ErrOIDCLoginFailed)
+	}
+
+	identity = &models.ExternalIdentity{
+		Provider:    provider,
+		Subject:     subject,
+		Email:       claims.Email,
+		LastLoginAt: time.Now(),
+	}
+
+	// Привязываем к существующему пользователю только если провайдер подтвердил email,
+	// иначе чужой аккаунт у провайдера с тем же адресом получил бы доступ к учетной записи
+	user, err := s.userRepo.GetByEmail(claims.Email)
+	if err == nil {
+		if !claims.EmailVerified {
+			return nil, ErrOIDCEmailConflict
+		}
+		identity.UserID = user.ID
+		if err := s.oidcRepo.CreateIdentity(identity); err != nil {
+			return nil, err
+		}
+		utils.LogOperation(s.ctx, "OIDCLink", user.ID, "External identity linked: "+provider)
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if !s.cfg.AutoProvision {
+		return nil, ErrOIDCAccountNotLinked
+	}
+
+	// Пароль у такого пользователя неизвестен никому, войти можно через провайдера
+	// или после сброса пароля
+	randomPassword, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := s.passwords.HashRandom(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	// Пользователи, созданные при входе через провайдера, попадают в организацию по умолчанию
+	user = &models.User{
+		OrgID:        models.DefaultOrgID,
+		Name:         oidcDisplayName(claims),
+		Email:        claims.Email,
+		PasswordHash: passwordHash,
+		Role:         models.RoleUser,
+	}
+	if claims.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := s.oidcRepo.CreateUserWithIdentity(user, identity); err != nil {
+		return nil, err
+	}
+
+	metrics.UserRegistrationsTotal.Inc()
+	utils.LogOperation(s.ctx, "OIDCProvision", user.ID, "User created from external identity: "+provider)
+	return user, nil
+}
+
+// client возвращает настроенный OAuth2 клиент и проверку ID-токенов
+// Настройки провайдера загружаются из discovery документа при первом вызове
+func (s *OIDCService) client() (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	if !s.cfg.Enabled() {
+		return nil, nil, ErrOIDCDisabled
+	}
+
+	p := s.provider
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(s.ctx, s.cfg.IssuerURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load oidc provider configuration: %w", err)
+	}
+
+	p.oauth = &oauth2.Config{
+		ClientID:     s.cfg.ClientID,
+		ClientSecret: s.cfg.ClientSecret,
+		RedirectURL:  s.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, s.cfg.Scopes...),
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+// oidcDisplayName выбирает имя пользователя из данных провайдера
+func oidcDisplayName(claims oidcClaims) string {
+	switch {
+	case claims.Name != "":
+		return claims.Name
+	case claims.PreferredUsername != "":
+		return claims.PreferredUsername
+	default:
+		return strings.Split(claims.Email, "@")[0]
+	}
+}
+
+// StateTTL возвращает, сколько действует начатый вход
+func (s *OIDCService) StateTTL() time.Duration {
+	return s.cfg.StateTTL
+}
+
+// SecureCookie проверяет, нужно ли ставить cookie с флагом Secure
+// (callback провайдера приходит по HTTPS)
+func (s *OIDCService) SecureCookie() bool {
+	return strings.HasPrefix(s.cfg.RedirectURL, "https://")
+}
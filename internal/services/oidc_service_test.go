@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/metrics"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm"
+)
+
+const (
+	testOIDCClientID = "go-crud-api"
+	testOIDCKeyID    = "test-key"
+)
+
+// mockIdentity описывает пользователя, который входит у провайдера
+type mockIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// mockAuthCode - выданный провайдером code и данные, с которыми начат вход
+type mockAuthCode struct {
+	identity  mockIdentity
+	challenge string
+	nonce     string
+}
+
+// mockOIDCProvider - провайдер OpenID Connect на httptest.Server
+// Поддерживает discovery, JWKS и обмен code на токены с проверкой PKCE S256
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockAuthCode
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	p := &mockOIDCProvider{key: key, codes: make(map[string]mockAuthCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockOIDCProvider) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.server.URL,
+		"authorization_endpoint":                p.server.URL + "/authorize",
+		"token_endpoint":                        p.server.URL + "/token",
+		"jwks_uri":                              p.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *mockOIDCProvider) jwks(w http.ResponseWriter, _ *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": testOIDCKeyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	code, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	if !ok || pkceChallenge(r.PostForm.Get("code_verifier")) != code.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            code.identity.Subject,
+		"aud":            testOIDCClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          code.nonce,
+		"email":          code.identity.Email,
+		"email_verified": code.identity.EmailVerified,
+		"name":           code.identity.Name,
+	})
+	idToken.Header["kid"] = testOIDCKeyID
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+// authorize имитирует вход пользователя у провайдера по адресу из Begin
+// Возвращает state и code, с которыми провайдер перенаправил бы пользователя на callback
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string, identity mockIdentity) (string, string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth url: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("client_id") != testOIDCClientID || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth request: %s", authURL)
+	}
+	if query.Get("code_challenge") == "" || query.Get("nonce") == "" || query.Get("state") == "" {
+		t.Fatalf("auth request without pkce, nonce or state: %s", authURL)
+	}
+
+	code := "code-" + identity.Subject + "-" + query.Get("state")[:8]
+	p.mu.Lock()
+	p.codes[code] = mockAuthCode{identity: identity, challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	p.mu.Unlock()
+	return query.Get("state"), code
+}
+
+// pkceChallenge вычисляет code_challenge по методу S256
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// newTestOIDCService создает сервис OIDC, подключенный к mock-провайдеру
+func newTestOIDCService(t *testing.T, autoProvision bool) (*OIDCService, *mockOIDCProvider, *gorm.DB) {
+	t.Helper()
+
+	provider := newMockOIDCProvider(t)
+	db := newTestDB(t, &models.ExternalIdentity{}, &models.OIDCAuthRequest{}, &models.PasswordHistory{})
+	service := NewOIDCService(
+		repository.NewUserRepository(db).System(),
+		repository.NewOIDCRepository(repository.WithoutTenant(db)),
+		newTestPasswordService(t, db),
+		config.OIDCConfig{
+			IssuerURL:     provider.server.URL,
+			ClientID:      testOIDCClientID,
+			ClientSecret:  "secret",
+			RedirectURL:   "http://localhost:8080/auth/oidc/callback",
+			AutoProvision: autoProvision,
+			StateTTL:      time.Minute,
+		},
+	)
+	return service, provider, db
+}
+
+// oidcLogin выполняет вход через mock-провайдер от начала до callback
+func oidcLogin(t *testing.T, service *OIDCService, provider *mockOIDCProvider, identity mockIdentity) (*models.User, error) {
+	t.Helper()
+
+	authURL, state, err := service.WithContext(context.Background()).Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	returnedState, code := provider.authorize(t, authURL, identity)
+	if returnedState != state {
+		t.Fatalf("provider received state %q, expected %q", returnedState, state)
+	}
+	return service.WithContext(context.Background()).Complete(state, code)
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, email string) *models.User {
+	t.Helper()
+
+	user := &models.User{Name: "Existing", Email: email, PasswordHash: "hash", Role: models.RoleUser}
+	if err := repository.NewUserRepository(db).ForOrg(models.DefaultOrgID).Create(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func countIdentities(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+
+	var count int64
+	if err := db.Model(&models.ExternalIdentity{}).Count(&count).Error; err != nil {
+		t.Fatalf("count identities: %v", err)
+	}
+	return count
+}
+
+func TestOIDCLoginProvisionsUserAndReusesIdentity(t *testing.T) {
+	service, provider, db := newTestOIDCService(t, true)
+	identity := mockIdentity{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+	registrations := testutil.ToFloat64(metrics.UserRegistrationsTotal)
+
+	user, err := oidcLogin(t, service, provider, identity)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if user.Email != identity.Email || user.Name != identity.Name || user.OrgID != models.DefaultOrgID {
+		t.Fatalf("unexpected provisioned user: %+v", user)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Fatal("email verified by the provider is not marked as verified")
+	}
+
+	again, err := oidcLogin(t, service, provider, identity)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.ID != user.ID {
+		t.Fatalf("second login returned user %d, expected %d", again.ID, user.ID)
+	}
+	if count := countIdentities(t, db); count != 1 {
+		t.Fatalf("expected 1 linked identity, got %d", count)
+	}
+	if got := testutil.ToFloat64(metrics.UserRegistrationsTotal) - registrations; got != 1 {
+		t.Fatalf("expected 1 registration to be counted, got %v", got)
+	}
+}
+
+func TestOIDCLoginLinksExistingUserByVerifiedEmail(t *testing.T) {
+	service, provider, db := newTestOIDCService(t, false)
+	existing := createTestUser(t, db, "bob@example.com")
+
+	user, err := oidcLogin(t, service, provider, mockIdentity{Subject: "bob-sub", Email: existing.Email, EmailVerified: true})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if user.ID != existing.ID {
+		t.Fatalf("identity linked to user %d, expected %d", user.ID, existing.ID)
+	}
+
+	var identity models.ExternalIdentity
+	if err := db.Where("subject = ?", "bob-sub").First(&identity).Error; err != nil {
+		t.Fatalf("identity was not linked: %v", err)
+	}
+	if identity.UserID != existing.ID || identity.Provider != provider.server.URL {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+}
+
+func TestOIDCLoginRejectsUnverifiedEmailOfExistingUser(t *testing.T) {
+	service, provider, db := newTestOIDCService(t, true)
+	existing := createTestUser(t, db, "carol@example.com")
+
+	_, err := oidcLogin(t, service, provider, mockIdentity{Subject: "mallory-sub", Email: existing.Email, EmailVerified: false})
+	if !errors.Is(err, ErrOIDCEmailConflict) {
+		t.Fatalf("expected ErrOIDCEmailConflict, got %v", err)
+	}
+	if count := countIdentities(t, db); count != 0 {
+		t.Fatalf("identity was linked to an existing account: %d", count)
+	}
+}
+
+func TestOIDCLoginWithoutAutoProvision(t *testing.T) {
+	service, provider, _ := newTestOIDCService(t, false)
+
+	_, err := oidcLogin(t, service, provider, mockIdentity{Subject: "dave-sub", Email: "dave@example.com", EmailVerified: true})
+	if !errors.Is(err, ErrOIDCAccountNotLinked) {
+		t.Fatalf("expected ErrOIDCAccountNotLinked, got %v", err)
+	}
+}
+
+func TestOIDCCompleteRejectsInvalidState(t *testing.T) {
+	identity := mockIdentity{Subject: "erin-sub", Email: "erin@example.com", EmailVerified: true}
+
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, service *OIDCService, provider *mockOIDCProvider, db *gorm.DB) (string, string)
+	}{
+		{"unknown state", func(t *testing.T, service *OIDCService, provider *mockOIDCProvider, _ *gorm.DB) (string, string) {
+			authURL, _, err := service.WithContext(context.Background()).Begin()
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			_, code := provider.authorize(t, authURL, identity)
+			return "forged-state", code
+		}},
+		{"replayed state", func(t *testing.T, service *OIDCService, provider *mockOIDCProvider, _ *gorm.DB) (string, string) {
+			authURL, state, err := service.WithContext(context.Background()).Begin()
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			_, code := provider.authorize(t, authURL, identity)
+			if _, err := service.WithContext(context.Background()).Complete(state, code); err != nil {
+				t.Fatalf("first callback: %v", err)
+			}
+			_, code = provider.authorize(t, authURL, identity)
+			return state, code
+		}},
+		{"expired state", func(t *testing.T, service *OIDCService, provider *mockOIDCProvider, db *gorm.DB) (string, string) {
+			authURL, state, err := service.WithContext(context.Background()).Begin()
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			if err := db.Model(&models.OIDCAuthRequest{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+				t.Fatalf("expire auth request: %v", err)
+			}
+			_, code := provider.authorize(t, authURL, identity)
+			return state, code
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, provider, db := newTestOIDCService(t, true)
+			state, code := tt.prepare(t, service, provider, db)
+
+			if _, err := service.WithContext(context.Background()).Complete(state, code); !errors.Is(err, ErrInvalidOIDCState) {
+				t.Fatalf("expected ErrInvalidOIDCState, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOIDCCompleteRejectsWrongPKCEVerifier(t *testing.T) {
+	service, provider, _ := newTestOIDCService(t, true)
+
+	authURL, state, err := service.WithContext(context.Background()).Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	_, code := provider.authorize(t, authURL, mockIdentity{Subject: "frank-sub", Email: "frank@example.com", EmailVerified: true})
+
+	// Code перехвачен и выдан для другого code_challenge
+	provider.mu.Lock()
+	issued := provider.codes[code]
+	issued.challenge = pkceChallenge("attacker-verifier")
+	provider.codes[code] = issued
+	provider.mu.Unlock()
+
+	if _, err := service.WithContext(context.Background()).Complete(state, code); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("expected ErrOIDCLoginFailed, got %v", err)
+	}
+}
+
+func TestOIDCCompleteRejectsNonceMismatch(t *testing.T) {
+	service, provider, _ := newTestOIDCService(t, true)
+
+	authURL, state, err := service.WithContext(context.Background()).Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	_, code := provider.authorize(t, authURL, mockIdentity{Subject: "grace-sub", Email: "grace@example.com", EmailVerified: true})
+
+	// ID-токен выпущен для другого входа
+	provider.mu.Lock()
+	issued := provider.codes[code]
+	issued.nonce = "another-nonce"
+	provider.codes[code] = issued
+	provider.mu.Unlock()
+
+	if _, err := service.WithContext(context.Background()).Complete(state, code); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("expected ErrOIDCLoginFailed, got %v", err)
+	}
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	db := newTestDB(t, &models.OIDCAuthRequest{}, &models.PasswordHistory{})
+	service := NewOIDCService(repository.NewUserRepository(db).System(), repository.NewOIDCRepository(db), newTestPasswordService(t, db), config.OIDCConfig{})
+
+	if _, _, err := service.WithContext(context.Background()).Begin(); !errors.Is(err, ErrOIDCDisabled) {
+		t.Fatalf("expected ErrOIDCDisabled, got %v", err)
+	}
+}
@@ -1,88 +1,596 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/metrics"
 	"go-crud-api/internal/models"
 	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrOrderNotOwned      = errors.New("order does not belong to user")
+	ErrInvalidTransition  = errors.New("invalid order status transition")
+	ErrOrderNotModifiable = errors.New("only pending orders can be modified")
+	ErrOrderNotDeletable  = errors.New("only pending or cancelled orders can be deleted")
+	ErrProductUnavailable = errors.New("product is unknown or inactive")
+	ErrInsufficientStock  = errors.New("insufficient stock")
+	ErrCurrencyMismatch   = errors.New("all order items must have the same currency")
+	ErrInvalidListParams  = errors.New("invalid list parameters")
 )
 
+// orderSortColumns описывает поля, по которым можно сортировать заказы
+// price - синоним итоговой суммы заказа
+var orderSortColumns = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"status":     "status",
+	"price":      "total",
+	"total":      "total",
+}
+
+// keysetSort - порядок записей при keyset-пагинации, параметр sort с курсором не поддерживается
+const keysetSort = "-created_at,-id"
+
+// orderTransitions описывает допустимые переходы между статусами заказа
+// Статусы cancelled и refunded являются конечными.
+// Отменить можно только неоплаченный заказ, оплаченный закрывается возвратом денег
+var orderTransitions = map[string][]string{
+	models.OrderStatusPending:   {models.OrderStatusPaid, models.OrderStatusCancelled},
+	models.OrderStatusPaid:      {models.OrderStatusShipped, models.OrderStatusRefunded},
+	models.OrderStatusShipped:   {models.OrderStatusDelivered},
+	models.OrderStatusDelivered: {models.OrderStatusRefunded},
+}
+
+// CanTransition проверяет, допустим ли переход заказа из статуса from в статус to
+func CanTransition(from, to string) bool {
+	for _, allowed := range orderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
 // OrderService содержит бизнес-логику для работы с заказами
-// Включает валидацию данных и проверку прав доступа
+// Включает валидацию данных и проверку прав доступа.
+// Заказы принадлежат организациям, операции выполняются через ForOrg
 type OrderService struct {
-	orderRepo *repository.OrderRepository
-	userRepo  *repository.UserRepository
+	orderRepo   *repository.OrderRepository
+	userRepo    *repository.UserRepository
+	productRepo *repository.ProductRepository
+	cursorCodec *utils.CursorCodec
+	authCfg     config.AuthConfig
+
+	ctx context.Context // Контекст запроса, задается WithContext
 }
 
-func NewOrderService(orderRepo *repository.OrderRepository, userRepo *repository.UserRepository) *OrderService {
+func NewOrderService(orderRepo *repository.OrderRepository, userRepo *repository.UserRepository, productRepo *repository.ProductRepository, cursorCodec *utils.CursorCodec, authCfg config.AuthConfig) *OrderService {
 	return &OrderService{
-		orderRepo: orderRepo,
-		userRepo:  userRepo,
+		orderRepo:   orderRepo,
+		userRepo:    userRepo,
+		productRepo: productRepo,
+		cursorCodec: cursorCodec,
+		authCfg:     authCfg,
 	}
 }
 
-// Create создает новый заказ
-// Проверяет существование пользователя и валидирует данные
-func (s *OrderService) Create(order *models.Order) error {
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *OrderService) WithContext(ctx context.Context) *OrderService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.orderRepo = s.orderRepo.WithContext(ctx)
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	scoped.productRepo = s.productRepo.WithContext(ctx)
+	return &scoped
+}
+
+// ForOrg возвращает сервис, работающий только с заказами и пользователями организации orgID
+func (s *OrderService) ForOrg(orgID uint) *OrderService {
+	scoped := *s
+	scoped.orderRepo = s.orderRepo.ForOrg(orgID)
+	scoped.userRepo = s.userRepo.ForOrg(orgID)
+	return &scoped
+}
+
+// Create создает новый заказ в статусе pending
+// Проверяет существование пользователя, валидирует данные и резервирует товар на складе.
+// Заказ и списание остатков выполняются в одной транзакции
+// actorID - пользователь, который создает заказ (сохраняется в истории статусов)
+func (s *OrderService) Create(order *models.Order, actorID uint) error {
+	s, span := startSpan(s, s.ctx, "OrderService.Create")
+	defer span.End()
+
 	// Проверяем существование пользователя
-	_, err := s.userRepo.GetByID(order.UserID)
+	user, err := s.userRepo.GetByID(order.UserID)
 	if err != nil {
 		return errors.New("user not found")
 	}
 
-	return s.orderRepo.Create(order)
+	// Заказы с неподтвержденным email разрешены только если это включено в конфигурации
+	if !s.authCfg.AllowUnverifiedOrders && user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+
+	err = s.orderRepo.Transaction(func(tx *gorm.DB) error {
+		products := s.productRepo.WithTx(tx)
+		if err := applyCatalog(products, order); err != nil {
+			return err
+		}
+		if err := reserveStock(products, order.Items); err != nil {
+			return err
+		}
+
+		order.Status = models.OrderStatusPending
+		if err := calculateTotals(order); err != nil {
+			return err
+		}
+		return s.orderRepo.WithTx(tx).Create(order, actorID)
+	})
+	if err != nil {
+		return err
+	}
+
+	metrics.OrdersCreatedTotal.WithLabelValues(order.Currency).Inc()
+	metrics.OrderValueTotal.WithLabelValues(order.Currency).Add(order.Total.Float64(order.Currency))
+	return nil
 }
 
 // GetByID получает информацию о заказе по ID
 // Возвращает ошибку если заказ не найден
 func (s *OrderService) GetByID(id uint) (*models.Order, error) {
+	s, span := startSpan(s, s.ctx, "OrderService.GetByID")
+	defer span.End()
+
 	return s.orderRepo.GetByID(id)
 }
 
-// GetByUserID получает все заказы пользователя
-// Возвращает пустой слайс если заказов нет
-func (s *OrderService) GetByUserID(userID uint) ([]models.Order, error) {
+// Update обновляет данные заказа и заменяет его позиции
+// Проверяет существование заказа и права доступа, пересчитывает суммы.
+// Остатки старых позиций возвращаются на склад, новые позиции резервируются
+func (s *OrderService) Update(order *models.Order) error {
+	s, span := startSpan(s, s.ctx, "OrderService.Update")
+	defer span.End()
+
+	return s.orderRepo.Transaction(func(tx *gorm.DB) error {
+		orders := s.orderRepo.WithTx(tx)
+		products := s.productRepo.WithTx(tx)
+
+		existingOrder, err := orders.GetByIDForUpdate(order.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+
+		// Проверяем, что заказ принадлежит пользователю
+		if existingOrder.UserID != order.UserID {
+			return ErrOrderNotOwned
+		}
+
+		// Изменять можно только заказ, который еще не оплачен
+		if existingOrder.Status != models.OrderStatusPending {
+			return ErrOrderNotModifiable
+		}
+
+		// Сохраняем оригинальные значения организации, created_at и статуса:
+		// статус меняется только через Transition
+		order.OrgID = existingOrder.OrgID
+		order.CreatedAt = existingOrder.CreatedAt
+		order.Status = existingOrder.Status
+
+		if err := releaseStock(products, existingOrder.Items); err != nil {
+			return err
+		}
+		if err := applyCatalog(products, order); err != nil {
+			return err
+		}
+		if err := reserveStock(products, order.Items); err != nil {
+			return err
+		}
+		if err := calculateTotals(order); err != nil {
+			return err
+		}
+
+		return orders.Update(order)
+	})
+}
+
+// Delete удаляет заказ по ID
+// Удалить можно только заказ в статусе pending или cancelled: оплаченный заказ остается в истории
+// даже после возврата денег. Остатки неоплаченного заказа возвращаются на склад
+func (s *OrderService) Delete(id uint) error {
+	s, span := startSpan(s, s.ctx, "OrderService.Delete")
+	defer span.End()
+
+	return s.orderRepo.Transaction(func(tx *gorm.DB) error {
+		orders := s.orderRepo.WithTx(tx)
+
+		order, err := orders.GetByIDForUpdate(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+
+		switch order.Status {
+		case models.OrderStatusPending:
+			if err := releaseStock(s.productRepo.WithTx(tx), order.Items); err != nil {
+				return err
+			}
+		case models.OrderStatusCancelled:
+			// Остатки вернулись на склад при отмене
+		default:
+			return fmt.Errorf("%w: order is %s", ErrOrderNotDeletable, order.Status)
+		}
+
+		return orders.Delete(order.ID)
+	})
+}
+
+// List возвращает страницу заказов
+// Проверяет параметры фильтрации и сортировки
+func (s *OrderService) List(params models.OrderListParams) (*models.Page[models.Order], error) {
+	s, span := startSpan(s, s.ctx, "OrderService.List")
+	defer span.End()
+
+	filter, err := buildOrderFilter(params)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, total, err := s.orderRepo.List(*filter)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPage(orders, filter.Page, filter.Limit, total), nil
+}
+
+// ListByUser возвращает страницу заказов пользователя
+// Возвращает ошибку если пользователь не найден
+func (s *OrderService) ListByUser(userID uint, params models.OrderListParams) (*models.Page[models.Order], error) {
+	s, span := startSpan(s, s.ctx, "OrderService.ListByUser")
+	defer span.End()
+
 	// Проверяем существование пользователя
 	_, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, errors.New("user not found")
 	}
 
-	return s.orderRepo.GetByUserID(userID)
+	params.UserID = userID
+	return s.List(params)
 }
 
-// Update обновляет данные заказа
-// Проверяет существование заказа и права доступа
-func (s *OrderService) Update(order *models.Order) error {
-	existingOrder, err := s.orderRepo.GetByID(order.ID)
+// ListByCursor возвращает страницу заказов с keyset-пагинацией
+// Заказы отсортированы по (created_at, id) от новых к старым, параметр sort не поддерживается
+func (s *OrderService) ListByCursor(params models.OrderListParams) (*models.CursorPage[models.Order], error) {
+	s, span := startSpan(s, s.ctx, "OrderService.ListByCursor")
+	defer span.End()
+
+	if params.Sort != "" {
+		return nil, fmt.Errorf("%w: sort is not supported with cursor pagination", ErrInvalidListParams)
+	}
+
+	filter, err := buildOrderFilter(params)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Проверяем, что заказ принадлежит пользователю
-	if existingOrder.UserID != order.UserID {
-		return errors.New("order does not belong to user")
+	var after *utils.Cursor
+	if params.Cursor != "" {
+		cursor, err := s.cursorCodec.Decode(params.Cursor, orderCursorScope(filter))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidListParams, err)
+		}
+		after = cursor
 	}
 
-	// Сохраняем оригинальное значение created_at
-	order.CreatedAt = existingOrder.CreatedAt
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	orders, err := s.orderRepo.ListAfter(*filter, after, filter.Limit+1)
+	if err != nil {
+		return nil, err
+	}
 
-	return s.orderRepo.Update(order)
+	page := &models.CursorPage[models.Order]{
+		Items: orders,
+		Limit: filter.Limit,
+	}
+	if len(orders) > filter.Limit {
+		page.Items = orders[:filter.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor, err = s.cursorCodec.Encode(utils.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, orderCursorScope(filter))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if page.Items == nil {
+		page.Items = []models.Order{}
+	}
+	return page, nil
 }
 
-// Delete удаляет заказ по ID
-// Проверяет существование заказа и права доступа
-func (s *OrderService) Delete(id uint) error {
-	order, err := s.orderRepo.GetByID(id)
+// orderCursorScope описывает выборку заказов, к которой привязан курсор:
+// фильтры и порядок keyset-пагинации. Размер страницы можно менять между запросами
+func orderCursorScope(filter *models.OrderFilter) string {
+	values := url.Values{
+		"user_id":      {strconv.FormatUint(uint64(filter.UserID), 10)},
+		"status":       {filter.Status},
+		"product_id":   {strconv.FormatUint(uint64(filter.ProductID), 10)},
+		"product":      {filter.Product},
+		"currency":     {filter.Currency},
+		"created_from": {filter.CreatedFrom.UTC().Format(time.RFC3339Nano)},
+		"created_to":   {filter.CreatedTo.UTC().Format(time.RFC3339Nano)},
+		"sort":         {keysetSort},
+	}
+	if filter.MinTotal != nil {
+		values.Set("min_total", strconv.FormatInt(int64(*filter.MinTotal), 10))
+	}
+	if filter.MaxTotal != nil {
+		values.Set("max_total", strconv.FormatInt(int64(*filter.MaxTotal), 10))
+	}
+	return "orders?" + values.Encode()
+}
+
+// ListByUserCursor возвращает страницу заказов пользователя с keyset-пагинацией
+// Возвращает ошибку если пользователь не найден
+func (s *OrderService) ListByUserCursor(userID uint, params models.OrderListParams) (*models.CursorPage[models.Order], error) {
+	s, span := startSpan(s, s.ctx, "OrderService.ListByUserCursor")
+	defer span.End()
+
+	// Проверяем существование пользователя
+	_, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	params.UserID = userID
+	return s.ListByCursor(params)
+}
+
+// Transition переводит заказ пользователя в новый статус
+// Проверяет принадлежность заказа и допустимость перехода,
+// сохраняет в истории, кто и из какого статуса перевел заказ.
+// Заказ блокируется до конца транзакции, чтобы параллельный Update не заменил позиции,
+// остатки которых возвращаются на склад при отмене
+func (s *OrderService) Transition(userID, orderID uint, to string, actorID uint, comment string) (*models.Order, error) {
+	s, span := startSpan(s, s.ctx, "OrderService.Transition")
+	defer span.End()
+
+	var order *models.Order
+	var from string
+	err := s.orderRepo.Transaction(func(tx *gorm.DB) error {
+		orders := s.orderRepo.WithTx(tx)
+
+		var err error
+		order, err = orders.GetByIDForUpdate(orderID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+
+		if order.UserID != userID {
+			return ErrOrderNotOwned
+		}
+
+		if !CanTransition(order.Status, to) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, order.Status, to)
+		}
+
+		from = order.Status
+		updated, err := orders.UpdateStatus(order.ID, &models.OrderStatusHistory{
+			OrderID:    order.ID,
+			FromStatus: &from,
+			ToStatus:   to,
+			ChangedBy:  actorID,
+			Comment:    comment,
+		})
+		if err != nil {
+			return err
+		}
+		if !updated {
+			// Статус изменился параллельным запросом
+			return fmt.Errorf("%w: order status was changed concurrently", ErrInvalidTransition)
+		}
+
+		// Отмененный заказ и возврат денег за неотправленный заказ возвращают товар на склад
+		if to == models.OrderStatusCancelled || (to == models.OrderStatusRefunded && from == models.OrderStatusPaid) {
+			return releaseStock(s.productRepo.WithTx(tx), order.Items)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.LogOrderOperation(s.ctx, "Transition", order.ID, actorID, from+" -> "+to)
+	if to == models.OrderStatusCancelled {
+		metrics.OrdersCancelledTotal.WithLabelValues(order.Currency).Inc()
+	}
+	return s.orderRepo.GetByID(order.ID)
+}
+
+// GetStatusHistory возвращает историю статусов заказа пользователя
+func (s *OrderService) GetStatusHistory(userID, orderID uint) ([]models.OrderStatusHistory, error) {
+	s, span := startSpan(s, s.ctx, "OrderService.GetStatusHistory")
+	defer span.End()
+
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+
+	if order.UserID != userID {
+		return nil, ErrOrderNotOwned
+	}
+
+	return s.orderRepo.GetStatusHistory(order.ID)
+}
+
+// buildOrderFilter проверяет параметры списка заказов и преобразует их в фильтр
+// По умолчанию заказы отсортированы от новых к старым
+func buildOrderFilter(params models.OrderListParams) (*models.OrderFilter, error) {
+	filter := &models.OrderFilter{
+		UserID:      params.UserID,
+		Status:      params.Status,
+		ProductID:   params.ProductID,
+		Product:     params.Product,
+		CreatedFrom: params.CreatedFrom,
+		CreatedTo:   params.CreatedTo,
+		Page:        params.Page,
+		Limit:       params.Limit,
+	}
+
+	// Устанавливаем значения по умолчанию
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 10
+	}
+
+	// Суммы разных валют несравнимы: диапазон суммы задается в одной валюте
+	// и ограничивает выборку заказами в ней
+	filter.Currency = params.Currency
+	if filter.Currency == "" && (params.MinPrice != "" || params.MaxPrice != "") {
+		filter.Currency = models.DefaultCurrency
+	}
+	if params.MinPrice != "" {
+		value, err := models.ParseMoney(params.MinPrice, filter.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: min_price: %v", ErrInvalidListParams, err)
+		}
+		filter.MinTotal = &value
+	}
+	if params.MaxPrice != "" {
+		value, err := models.ParseMoney(params.MaxPrice, filter.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: max_price: %v", ErrInvalidListParams, err)
+		}
+		filter.MaxTotal = &value
+	}
+
+	sort := params.Sort
+	if sort == "" {
+		sort = "-created_at"
+	}
+	for _, name := range strings.Split(sort, ",") {
+		name = strings.TrimSpace(name)
+		desc := strings.HasPrefix(name, "-")
+		column, ok := orderSortColumns[strings.TrimPrefix(name, "-")]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidListParams, name)
+		}
+		filter.Sort = append(filter.Sort, models.SortField{Column: column, Desc: desc})
+	}
+
+	return filter, nil
+}
+
+// applyCatalog заполняет позиции заказа данными из каталога
+// Фиксирует название, текущую цену и валюту товара, отклоняет неизвестные и неактивные товары.
+// Все позиции заказа должны быть в одной валюте. Строки товаров блокируются до конца транзакции
+func applyCatalog(products *repository.ProductRepository, order *models.Order) error {
+	ids := make([]uint, 0, len(order.Items))
+	for _, item := range order.Items {
+		if item.ProductID == nil {
+			return ErrProductUnavailable
+		}
+		ids = append(ids, *item.ProductID)
+	}
+
+	found, err := products.GetByIDsForUpdate(ids)
 	if err != nil {
 		return err
 	}
 
-	return s.orderRepo.Delete(order.ID)
+	catalog := make(map[uint]models.Product, len(found))
+	for _, product := range found {
+		catalog[product.ID] = product
+	}
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		product, ok := catalog[*item.ProductID]
+		if !ok || !product.Active {
+			return fmt.Errorf("%w: %d", ErrProductUnavailable, *item.ProductID)
+		}
+
+		if i == 0 {
+			order.Currency = product.Currency
+		} else if product.Currency != order.Currency {
+			return ErrCurrencyMismatch
+		}
+
+		item.Product = product.Name
+		item.UnitPrice = product.Price
+	}
+	return nil
 }
 
-// List возвращает список всех заказов с пагинацией
-// Поддерживает фильтрацию и сортировку
-func (s *OrderService) List(page, limit int) ([]models.Order, int64, error) {
-	return s.orderRepo.List(page, limit)
+// reserveStock списывает со склада товары позиций заказа
+// Возвращает ErrInsufficientStock если какого-то товара не хватает
+func reserveStock(products *repository.ProductRepository, items []models.OrderItem) error {
+	for _, item := range items {
+		reserved, err := products.ReserveStock(*item.ProductID, item.Quantity)
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			return fmt.Errorf("%w: product %d", ErrInsufficientStock, *item.ProductID)
+		}
+	}
+	return nil
+}
+
+// releaseStock возвращает на склад товары позиций заказа
+// Позиции, созданные до появления каталога, пропускаются
+func releaseStock(products *repository.ProductRepository, items []models.OrderItem) error {
+	for _, item := range items {
+		if item.ProductID == nil {
+			continue
+		}
+		if err := products.ReleaseStock(*item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// calculateTotals считает суммы позиций и заказа на сервере
+// Суммы, переданные клиентом, не используются.
+// Возвращает models.ErrMoneyOverflow, если сумма не помещается в int64
+func calculateTotals(order *models.Order) error {
+	var subtotal models.Money
+	for i := range order.Items {
+		item := &order.Items[i]
+		lineTotal, err := item.UnitPrice.Mul(item.Quantity)
+		if err != nil {
+			return err
+		}
+		item.LineTotal = lineTotal
+		if subtotal, err = subtotal.Add(lineTotal); err != nil {
+			return err
+		}
+	}
+
+	order.Subtotal = subtotal
+	order.Total = order.Subtotal
+	return nil
 }
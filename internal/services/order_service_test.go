@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// newTestOrderService создает сервис заказов организации по умолчанию
+// Заказы можно создавать без подтвержденного email
+func newTestOrderService(t *testing.T) (*OrderService, *gorm.DB) {
+	t.Helper()
+
+	db := newTestDB(t, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.OrderStatusHistory{})
+	service := NewOrderService(
+		repository.NewOrderRepository(db),
+		repository.NewUserRepository(db),
+		repository.NewProductRepository(db),
+		utils.NewCursorCodec("secret"),
+		config.AuthConfig{AllowUnverifiedOrders: true},
+	)
+	return service.WithContext(context.Background()).ForOrg(models.DefaultOrgID), db
+}
+
+// createTestProduct создает активный товар с остатком stock
+func createTestProduct(t *testing.T, db *gorm.DB, name string, stock int) *models.Product {
+	t.Helper()
+
+	product := &models.Product{Name: name, Price: 1000, Currency: "USD", Stock: stock, Active: true}
+	if err := repository.NewProductRepository(db).Create(product); err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return product
+}
+
+// orderItem возвращает позицию заказа товара product в количестве quantity
+func orderItem(product *models.Product, quantity int) models.OrderItem {
+	return models.OrderItem{ProductID: &product.ID, Quantity: quantity}
+}
+
+// assertStock проверяет остатки товаров на складе: want - ожидаемый остаток по ID товара
+func assertStock(t *testing.T, db *gorm.DB, want map[uint]int) {
+	t.Helper()
+
+	for id, stock := range want {
+		product, err := repository.NewProductRepository(db).GetByID(id)
+		if err != nil {
+			t.Fatalf("get product %d: %v", id, err)
+		}
+		if product.Stock != stock {
+			t.Errorf("product %d: stock %d, want %d", id, product.Stock, stock)
+		}
+	}
+}
+
+func TestOrderStockReservation(t *testing.T) {
+	service, db := newTestOrderService(t)
+	user := createTestUser(t, db, "alice@example.com")
+	a := createTestProduct(t, db, "A", 10)
+	b := createTestProduct(t, db, "B", 5)
+
+	order := &models.Order{UserID: user.ID, Items: []models.OrderItem{orderItem(a, 2)}}
+	if err := service.Create(order, user.ID); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 8, b.ID: 5})
+
+	// Если одного товара не хватает, не резервируется ни один
+	short := &models.Order{UserID: user.ID, Items: []models.OrderItem{orderItem(a, 1), orderItem(b, 6)}}
+	if err := service.Create(short, user.ID); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock, got %v", err)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 8, b.ID: 5})
+
+	// Замена позиций возвращает старые товары и резервирует новые
+	update := &models.Order{ID: order.ID, UserID: user.ID, Items: []models.OrderItem{orderItem(b, 3)}}
+	if err := service.Update(update); err != nil {
+		t.Fatalf("update order: %v", err)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 10, b.ID: 2})
+
+	// Отмена возвращает товар, удаление отмененного заказа не возвращает его повторно
+	if _, err := service.Transition(user.ID, order.ID, models.OrderStatusCancelled, user.ID, ""); err != nil {
+		t.Fatalf("cancel order: %v", err)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 10, b.ID: 5})
+	if err := service.Delete(order.ID); err != nil {
+		t.Fatalf("delete cancelled order: %v", err)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 10, b.ID: 5})
+
+	// Удаление неоплаченного заказа возвращает товар
+	pending := &models.Order{UserID: user.ID, Items: []models.OrderItem{orderItem(a, 4)}}
+	if err := service.Create(pending, user.ID); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 6})
+	if err := service.Delete(pending.ID); err != nil {
+		t.Fatalf("delete pending order: %v", err)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 10})
+
+	// Оплата не меняет остатки
+	paid := &models.Order{UserID: user.ID, Items: []models.OrderItem{orderItem(a, 1)}}
+	if err := service.Create(paid, user.ID); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	if _, err := service.Transition(user.ID, paid.ID, models.OrderStatusPaid, user.ID, ""); err != nil {
+		t.Fatalf("pay order: %v", err)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 9})
+}
+
+func TestTransitionCancelRacingUpdate(t *testing.T) {
+	service, db := newTestOrderService(t)
+	user := createTestUser(t, db, "alice@example.com")
+	a := createTestProduct(t, db, "A", 10)
+	b := createTestProduct(t, db, "B", 10)
+
+	order := &models.Order{UserID: user.ID, Items: []models.OrderItem{orderItem(a, 2)}}
+	if err := service.Create(order, user.ID); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	// Как только отмена прочитала заказ, параллельно заменяем его позиции.
+	// Если заказ не заблокирован, Update успевает завершиться до того, как отмена вернет товар на склад
+	var triggered atomic.Bool
+	updateDone := make(chan error, 1)
+	err := db.Callback().Query().After("gorm:query").Register("test:update_during_cancel", func(tx *gorm.DB) {
+		if tx.Statement.Schema == nil || tx.Statement.Schema.Table != "orders" || !triggered.CompareAndSwap(false, true) {
+			return
+		}
+		go func() {
+			updateDone <- service.Update(&models.Order{ID: order.ID, UserID: user.ID, Items: []models.OrderItem{orderItem(b, 3)}})
+		}()
+		select {
+		case err := <-updateDone:
+			updateDone <- err
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	if _, err := service.Transition(user.ID, order.ID, models.OrderStatusCancelled, user.ID, ""); err != nil {
+		t.Fatalf("cancel order: %v", err)
+	}
+	if err := <-updateDone; !errors.Is(err, ErrOrderNotModifiable) {
+		t.Fatalf("update of cancelled order: expected ErrOrderNotModifiable, got %v", err)
+	}
+
+	assertStock(t, db, map[uint]int{a.ID: 10, b.ID: 10})
+}
+
+func TestCanTransition(t *testing.T) {
+	statuses := []string{
+		models.OrderStatusPending,
+		models.OrderStatusPaid,
+		models.OrderStatusShipped,
+		models.OrderStatusDelivered,
+		models.OrderStatusCancelled,
+		models.OrderStatusRefunded,
+	}
+	// allowed - все допустимые переходы, остальные пары статусов запрещены
+	allowed := map[[2]string]bool{
+		{models.OrderStatusPending, models.OrderStatusPaid}:       true,
+		{models.OrderStatusPending, models.OrderStatusCancelled}:  true,
+		{models.OrderStatusPaid, models.OrderStatusShipped}:       true,
+		{models.OrderStatusPaid, models.OrderStatusRefunded}:      true,
+		{models.OrderStatusShipped, models.OrderStatusDelivered}:  true,
+		{models.OrderStatusDelivered, models.OrderStatusRefunded}: true,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if got := CanTransition(from, to); got != allowed[[2]string{from, to}] {
+				t.Errorf("%s -> %s: got %v, want %v", from, to, got, !got)
+			}
+		}
+	}
+	if CanTransition("unknown", models.OrderStatusPaid) || CanTransition(models.OrderStatusPending, "unknown") {
+		t.Error("transition with an unknown status is allowed")
+	}
+}
+
+func TestPaidOrderIsRefundedNotCancelled(t *testing.T) {
+	service, db := newTestOrderService(t)
+	user := createTestUser(t, db, "alice@example.com")
+	a := createTestProduct(t, db, "A", 10)
+
+	order := &models.Order{UserID: user.ID, Items: []models.OrderItem{orderItem(a, 2)}}
+	if err := service.Create(order, user.ID); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	if _, err := service.Transition(user.ID, order.ID, models.OrderStatusPaid, user.ID, ""); err != nil {
+		t.Fatalf("pay order: %v", err)
+	}
+
+	// Оплаченный заказ нельзя отменить, а затем удалить
+	if _, err := service.Transition(user.ID, order.ID, models.OrderStatusCancelled, user.ID, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("cancel paid order: expected ErrInvalidTransition, got %v", err)
+	}
+	if err := service.Delete(order.ID); !errors.Is(err, ErrOrderNotDeletable) {
+		t.Fatalf("delete paid order: expected ErrOrderNotDeletable, got %v", err)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 8})
+
+	// Возврат денег за неотправленный заказ возвращает товар на склад
+	refunded, err := service.Transition(user.ID, order.ID, models.OrderStatusRefunded, user.ID, "")
+	if err != nil {
+		t.Fatalf("refund order: %v", err)
+	}
+	if refunded.Status != models.OrderStatusRefunded {
+		t.Fatalf("status %s, want %s", refunded.Status, models.OrderStatusRefunded)
+	}
+	assertStock(t, db, map[uint]int{a.ID: 10})
+	if err := service.Delete(order.ID); !errors.Is(err, ErrOrderNotDeletable) {
+		t.Fatalf("delete refunded order: expected ErrOrderNotDeletable, got %v", err)
+	}
+}
+
+func TestRefundOfDeliveredOrderKeepsStock(t *testing.T) {
+	service, db := newTestOrderService(t)
+	user := createTestUser(t, db, "alice@example.com")
+	a := createTestProduct(t, db, "A", 10)
+
+	order := &models.Order{UserID: user.ID, Items: []models.OrderItem{orderItem(a, 2)}}
+	if err := service.Create(order, user.ID); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	for _, to := range []string{models.OrderStatusPaid, models.OrderStatusShipped, models.OrderStatusDelivered, models.OrderStatusRefunded} {
+		if _, err := service.Transition(user.ID, order.ID, to, user.ID, ""); err != nil {
+			t.Fatalf("transition to %s: %v", to, err)
+		}
+	}
+	// Доставленный товар остается у покупателя
+	assertStock(t, db, map[uint]int{a.ID: 8})
+}
+
+func TestOrderCursorIsBoundToListing(t *testing.T) {
+	service, db := newTestOrderService(t)
+	user := createTestUser(t, db, "alice@example.com")
+	a := createTestProduct(t, db, "A", 10)
+	for i := 0; i < 3; i++ {
+		if err := service.Create(&models.Order{UserID: user.ID, Items: []models.OrderItem{orderItem(a, 1)}}, user.ID); err != nil {
+			t.Fatalf("create order: %v", err)
+		}
+	}
+
+	params := models.OrderListParams{Limit: 1, Status: models.OrderStatusPending}
+	first, err := service.ListByCursor(params)
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if first.NextCursor == "" {
+		t.Fatal("expected next cursor")
+	}
+
+	// Размер страницы можно менять, сохраняя курсор
+	params.Cursor = first.NextCursor
+	params.Limit = 2
+	if _, err := service.ListByCursor(params); err != nil {
+		t.Fatalf("next page: %v", err)
+	}
+
+	// Курсор не принимается списком с другими фильтрами и списком пользователей
+	other := params
+	other.Status = models.OrderStatusPaid
+	if _, err := service.ListByCursor(other); !errors.Is(err, ErrInvalidListParams) {
+		t.Fatalf("other filters: expected ErrInvalidListParams, got %v", err)
+	}
+	users, _ := newTestUserService(t)
+	if _, err := users.ListUsersByCursor(models.PaginationParams{Limit: 1, Cursor: first.NextCursor}); !errors.Is(err, utils.ErrInvalidCursor) {
+		t.Fatalf("users list: expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestMissingOrderReturnsErrOrderNotFound(t *testing.T) {
+	service, db := newTestOrderService(t)
+	user := createTestUser(t, db, "alice@example.com")
+	a := createTestProduct(t, db, "A", 10)
+	const missingID = 404
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"update", func() error {
+			return service.Update(&models.Order{ID: missingID, UserID: user.ID, Items: []models.OrderItem{orderItem(a, 1)}})
+		}},
+		{"delete", func() error { return service.Delete(missingID) }},
+		{"transition", func() error {
+			_, err := service.Transition(user.ID, missingID, models.OrderStatusCancelled, user.ID, "")
+			return err
+		}},
+		{"history", func() error { _, err := service.GetStatusHistory(user.ID, missingID); return err }},
+	}
+	for _, tt := range tests {
+		if err := tt.run(); !errors.Is(err, ErrOrderNotFound) {
+			t.Errorf("%s: expected ErrOrderNotFound, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+)
+
+var (
+	ErrInvalidOrganizationSlug = errors.New("slug may contain only lowercase letters, digits and hyphens")
+	ErrOrganizationSlugTaken   = errors.New("organization with this slug already exists")
+)
+
+// organizationSlugPattern - допустимый формат slug организации
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// OrganizationService содержит бизнес-логику для работы с организациями
+// Организациями управляют только администраторы платформы
+type OrganizationService struct {
+	orgRepo *repository.OrganizationRepository
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+func NewOrganizationService(orgRepo *repository.OrganizationRepository) *OrganizationService {
+	return &OrganizationService{orgRepo: orgRepo}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *OrganizationService) WithContext(ctx context.Context) *OrganizationService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.orgRepo = s.orgRepo.WithContext(ctx)
+	return &scoped
+}
+
+// Create создает новую организацию
+// Slug должен быть уникальным
+func (s *OrganizationService) Create(req models.CreateOrganizationRequest) (*models.Organization, error) {
+	s, span := startSpan(s, s.ctx, "OrganizationService.Create")
+	defer span.End()
+
+	if !organizationSlugPattern.MatchString(req.Slug) {
+		return nil, ErrInvalidOrganizationSlug
+	}
+
+	exists, err := s.orgRepo.CheckSlugExists(req.Slug)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrOrganizationSlugTaken
+	}
+
+	org := &models.Organization{Name: req.Name, Slug: req.Slug}
+	if err := s.orgRepo.Create(org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// List возвращает все организации
+func (s *OrganizationService) List() ([]models.Organization, error) {
+	s, span := startSpan(s, s.ctx, "OrganizationService.List")
+	defer span.End()
+
+	return s.orgRepo.List()
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/mail"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// PasswordResetService реализует восстановление пароля через одноразовые токены,
+// которые отправляются пользователю на почту
+type PasswordResetService struct {
+	userRepo   *repository.UserRepository
+	resetRepo  *repository.PasswordResetRepository
+	tokenRepo  *repository.TokenRepository
+	apiKeyRepo *repository.APIKeyRepository
+	passwords  *PasswordService
+	mailer     mail.Sender
+	cfg        config.AuthConfig
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+func NewPasswordResetService(userRepo *repository.UserRepository, resetRepo *repository.PasswordResetRepository, tokenRepo *repository.TokenRepository, apiKeyRepo *repository.APIKeyRepository, passwords *PasswordService, mailer mail.Sender, cfg config.AuthConfig) *PasswordResetService {
+	return &PasswordResetService{
+		userRepo:   userRepo,
+		resetRepo:  resetRepo,
+		tokenRepo:  tokenRepo,
+		apiKeyRepo: apiKeyRepo,
+		passwords:  passwords,
+		mailer:     mailer,
+		cfg:        cfg,
+	}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *PasswordResetService) WithContext(ctx context.Context) *PasswordResetService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	scoped.resetRepo = s.resetRepo.WithContext(ctx)
+	scoped.tokenRepo = s.tokenRepo.WithContext(ctx)
+	scoped.apiKeyRepo = s.apiKeyRepo.WithContext(ctx)
+	scoped.passwords = s.passwords.WithContext(ctx)
+	return &scoped
+}
+
+// RequestReset создает токен сброса пароля и отправляет ссылку на почту
+// Для неизвестного email ничего не делает и не возвращает ошибку,
+// чтобы по ответу нельзя было определить, зарегистрирован ли адрес
+func (s *PasswordResetService) RequestReset(email string) error {
+	s, span := startSpan(s, s.ctx, "PasswordResetService.RequestReset")
+	defer span.End()
+
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// Действует только последняя отправленная ссылка
+	if err := s.resetRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+
+	rawToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	token := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(rawToken),
+		ExpiresAt: time.Now().Add(s.cfg.PasswordResetTTL),
+	}
+	if err := s.resetRepo.Create(token); err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.cfg.PublicURL, "/") + "/reset-password?token=" + url.QueryEscape(rawToken)
+	utils.LogOperation(s.ctx, "PasswordResetRequested", user.ID, "Password reset link sent")
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nДля установки нового пароля перейдите по ссылке:\n%s\n\n"+
+			"Ссылка действует %s и может быть использована один раз.\n"+
+			"Если вы не запрашивали сброс пароля, просто проигнорируйте это письмо.",
+			user.Name, link, s.cfg.PasswordResetTTL),
+	})
+}
+
+// ResetPassword устанавливает новый пароль по токену из письма
+// Токен становится недействительным, все сессии и ключи API пользователя отзываются.
+// Все изменения выполняются в одной транзакции: при ошибке пароль, история паролей и токен остаются прежними
+func (s *PasswordResetService) ResetPassword(rawToken, password string) error {
+	s, span := startSpan(s, s.ctx, "PasswordResetService.ResetPassword")
+	defer span.End()
+
+	token, err := s.resetRepo.GetByHash(utils.HashToken(rawToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	var revokedKeys int64
+	err = s.resetRepo.Transaction(func(tx *gorm.DB) error {
+		// Пароль проверяется до использования токена: отклоненный пароль не сжигает ссылку
+		if err := s.passwords.WithTx(tx).SetPassword(user, password); err != nil {
+			return err
+		}
+
+		// Помечаем токен использованным до сохранения пароля, чтобы его нельзя было применить дважды
+		used, err := s.resetRepo.WithTx(tx).MarkUsed(token.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidResetToken
+		}
+
+		if err := s.userRepo.WithTx(tx).Update(user); err != nil {
+			return err
+		}
+
+		if err := s.resetRepo.WithTx(tx).InvalidateForUser(user.ID); err != nil {
+			return err
+		}
+
+		// Завершаем все сессии и отзываем ключи API: если пароль был украден,
+		// злоумышленник потеряет доступ, в том числе через выпущенный им ключ
+		if err := s.tokenRepo.WithTx(tx).RevokeUserSessions(user.ID, "password_reset"); err != nil {
+			return err
+		}
+		revokedKeys, err = s.apiKeyRepo.WithTx(tx).RevokeAllForUser(user.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	utils.LogOperation(s.ctx, "PasswordReset", user.ID, fmt.Sprintf("Password changed via reset link, %d API keys revoked", revokedKeys))
+	return nil
+}
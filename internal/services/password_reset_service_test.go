@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+const testResetToken = "reset-token"
+
+// newTestResetService создает сервис сброса пароля и пользователя с токеном сброса, сессией и ключами API
+func newTestResetService(t *testing.T) (*PasswordResetService, *gorm.DB, *models.User) {
+	t.Helper()
+
+	db := newTestDB(t, &models.PasswordResetToken{}, &models.PasswordHistory{}, &models.Session{}, &models.RefreshToken{}, &models.APIKey{})
+	passwords := newTestPasswordService(t, db)
+
+	hash, err := passwords.WithContext(context.Background()).HashRandom("old-password")
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := &models.User{Name: "Alice", Email: "alice@example.com", PasswordHash: hash, Role: models.RoleUser}
+	if err := repository.NewUserRepository(db).ForOrg(models.DefaultOrgID).Create(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	fixtures := []interface{}{
+		&models.PasswordResetToken{UserID: user.ID, TokenHash: utils.HashToken(testResetToken), ExpiresAt: time.Now().Add(time.Hour)},
+		&models.Session{ID: "session", UserID: user.ID, LastSeenAt: time.Now()},
+		&models.APIKey{UserID: user.ID, Name: "ci", Prefix: "aaaa", KeyHash: "hash-a", Scopes: []string{}},
+		&models.APIKey{UserID: user.ID, Name: "reports", Prefix: "bbbb", KeyHash: "hash-b", Scopes: []string{models.ScopeOrdersRead}},
+	}
+	for _, fixture := range fixtures {
+		if err := db.Create(fixture).Error; err != nil {
+			t.Fatalf("create fixture: %v", err)
+		}
+	}
+
+	service := NewPasswordResetService(
+		repository.NewUserRepository(db).System(),
+		repository.NewPasswordResetRepository(db),
+		repository.NewTokenRepository(db),
+		repository.NewAPIKeyRepository(db),
+		passwords,
+		nil,
+		config.AuthConfig{PasswordResetTTL: time.Hour},
+	)
+	return service, db, user
+}
+
+// countRows возвращает количество записей модели, подходящих под условие
+func countRows(t *testing.T, db *gorm.DB, model interface{}, query string, args ...interface{}) int64 {
+	t.Helper()
+
+	var count int64
+	if err := db.Model(model).Where(query, args...).Count(&count).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return count
+}
+
+func TestResetPasswordRevokesSessionsAndAPIKeys(t *testing.T) {
+	service, db, user := newTestResetService(t)
+
+	if err := service.WithContext(context.Background()).ResetPassword(testResetToken, "new-password"); err != nil {
+		t.Fatalf("reset password: %v", err)
+	}
+
+	updated, err := repository.NewUserRepository(db).System().GetByID(user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if !newTestPasswordService(t, db).WithContext(context.Background()).Verify("new-password", updated.PasswordHash) {
+		t.Fatal("new password was not saved")
+	}
+
+	if n := countRows(t, db, &models.PasswordResetToken{}, "used_at IS NULL"); n != 0 {
+		t.Fatalf("reset token is still usable: %d", n)
+	}
+	if n := countRows(t, db, &models.PasswordHistory{}, "user_id = ?", user.ID); n != 1 {
+		t.Fatalf("expected previous password in history, got %d entries", n)
+	}
+	if n := countRows(t, db, &models.Session{}, "revoked_at IS NULL"); n != 0 {
+		t.Fatalf("sessions survived the reset: %d", n)
+	}
+	if n := countRows(t, db, &models.APIKey{}, "revoked_at IS NULL"); n != 0 {
+		t.Fatalf("API keys survived the reset: %d", n)
+	}
+
+	if err := service.WithContext(context.Background()).ResetPassword(testResetToken, "another-password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("token reuse: expected ErrInvalidResetToken, got %v", err)
+	}
+}
+
+func TestResetPasswordRollsBackOnFailure(t *testing.T) {
+	service, db, user := newTestResetService(t)
+
+	errUpdateFailed := errors.New("update failed")
+	err := db.Callback().Update().Before("gorm:update").Register("test:fail_user_update", func(tx *gorm.DB) {
+		if tx.Statement.Schema != nil && tx.Statement.Schema.Table == "users" {
+			tx.AddError(errUpdateFailed)
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	if err := service.WithContext(context.Background()).ResetPassword(testResetToken, "new-password"); !errors.Is(err, errUpdateFailed) {
+		t.Fatalf("expected update error, got %v", err)
+	}
+
+	if n := countRows(t, db, &models.PasswordResetToken{}, "used_at IS NULL"); n != 1 {
+		t.Fatal("reset token was burned although the password was not changed")
+	}
+	if n := countRows(t, db, &models.PasswordHistory{}, "user_id = ?", user.ID); n != 0 {
+		t.Fatalf("stale password history entries: %d", n)
+	}
+	if n := countRows(t, db, &models.Session{}, "revoked_at IS NULL"); n != 1 {
+		t.Fatal("sessions were revoked although the password was not changed")
+	}
+	if n := countRows(t, db, &models.APIKey{}, "revoked_at IS NULL"); n != 2 {
+		t.Fatal("API keys were revoked although the password was not changed")
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// bcrypt учитывает только первые 72 байта пароля
+const bcryptMaxPasswordBytes = 72
+
+var (
+	ErrWeakPassword     = errors.New("password does not meet the password policy")
+	ErrPasswordReused   = errors.New("password was used recently, choose a different one")
+	ErrPasswordBreached = errors.New("password appears in a list of breached passwords, choose a different one")
+)
+
+// IsPasswordPolicyError сообщает, что пароль отклонен политикой паролей
+func IsPasswordPolicyError(err error) bool {
+	return errors.Is(err, ErrWeakPassword) || errors.Is(err, ErrPasswordReused) || errors.Is(err, ErrPasswordBreached)
+}
+
+// PasswordService объединяет политику паролей, хеширование и историю паролей
+// Все места, где задается или проверяется пароль, работают через него
+type PasswordService struct {
+	historyRepo *repository.PasswordHistoryRepository
+	hasher      *utils.PasswordHasher
+	cfg         config.PasswordConfig
+	breached    map[string]struct{}
+	dummy       *dummyPassword
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+// NewPasswordService создает сервис паролей
+// Список утекших паролей загружается из файла один раз при запуске
+func NewPasswordService(historyRepo *repository.PasswordHistoryRepository, cfg config.PasswordConfig) (*PasswordService, error) {
+	breached, err := loadBreachedPasswords(cfg.BreachedListFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasswordService{
+		historyRepo: historyRepo,
+		hasher:      utils.NewPasswordHasher(cfg),
+		cfg:         cfg,
+		breached:    breached,
+		dummy:       &dummyPassword{},
+	}, nil
+}
+
+// dummyPassword - хеш для проверки пароля неизвестного пользователя, создается при первом использовании
+// Хранится по указателю, чтобы копии сервиса из WithContext не создавали его заново
+type dummyPassword struct {
+	once sync.Once
+	hash string
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *PasswordService) WithContext(ctx context.Context) *PasswordService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.historyRepo = s.historyRepo.WithContext(ctx)
+	return &scoped
+}
+
+// WithTx возвращает сервис, сохраняющий историю паролей в транзакции tx
+func (s *PasswordService) WithTx(tx *gorm.DB) *PasswordService {
+	scoped := *s
+	scoped.historyRepo = s.historyRepo.WithTx(tx)
+	return &scoped
+}
+
+// Validate проверяет пароль на соответствие политике
+// Возвращает ErrWeakPassword с перечнем нарушений или ErrPasswordBreached
+func (s *PasswordService) Validate(password string) error {
+	var problems []string
+
+	length := utf8.RuneCountInString(password)
+	if length < s.cfg.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", s.cfg.MinLength))
+	}
+	if length > s.cfg.MaxLength || (s.cfg.Algorithm == "bcrypt" && len(password) > bcryptMaxPasswordBytes) {
+		problems = append(problems, "too long")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	if s.cfg.RequireUpper && !hasUpper {
+		problems = append(problems, "an uppercase letter")
+	}
+	if s.cfg.RequireLower && !hasLower {
+		problems = append(problems, "a lowercase letter")
+	}
+	if s.cfg.RequireDigit && !hasDigit {
+		problems = append(problems, "a digit")
+	}
+	if s.cfg.RequireSymbol && !hasSymbol {
+		problems = append(problems, "a symbol")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrWeakPassword, strings.Join(problems, ", "))
+	}
+
+	if _, ok := s.breached[strings.ToLower(password)]; ok {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+// HashNew проверяет пароль нового пользователя и возвращает его хеш
+func (s *PasswordService) HashNew(password string) (string, error) {
+	s, span := startSpan(s, s.ctx, "PasswordService.HashNew")
+	defer span.End()
+
+	if err := s.Validate(password); err != nil {
+		return "", err
+	}
+	return s.hasher.Hash(password)
+}
+
+// HashRandom возвращает хеш пароля, сгенерированного системой
+// Политика к нему не применяется
+func (s *PasswordService) HashRandom(password string) (string, error) {
+	s, span := startSpan(s, s.ctx, "PasswordService.HashRandom")
+	defer span.End()
+
+	return s.hasher.Hash(password)
+}
+
+// SetPassword проверяет новый пароль пользователя и записывает его хеш в user.PasswordHash
+// Пароль не должен совпадать с текущим и с HistorySize-1 предыдущими.
+// Текущий хеш переносится в историю; сохранить пользователя должен вызывающий код
+func (s *PasswordService) SetPassword(user *models.User, password string) error {
+	s, span := startSpan(s, s.ctx, "PasswordService.SetPassword")
+	defer span.End()
+
+	if err := s.Validate(password); err != nil {
+		return err
+	}
+
+	if s.cfg.HistorySize > 0 && user.PasswordHash != "" {
+		if s.hasher.Verify(password, user.PasswordHash) {
+			return ErrPasswordReused
+		}
+
+		previous, err := s.historyRepo.Recent(user.ID, s.cfg.HistorySize-1)
+		if err != nil {
+			return err
+		}
+		for _, entry := range previous {
+			if s.hasher.Verify(password, entry.PasswordHash) {
+				return ErrPasswordReused
+			}
+		}
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.HistorySize > 1 && user.PasswordHash != "" {
+		if err := s.historyRepo.Create(&models.PasswordHistory{UserID: user.ID, PasswordHash: user.PasswordHash}); err != nil {
+			return err
+		}
+		if err := s.historyRepo.Trim(user.ID, s.cfg.HistorySize-1); err != nil {
+			utils.LogError(s.ctx, "PasswordHistoryTrim", err)
+		}
+	}
+
+	user.PasswordHash = hash
+	return nil
+}
+
+// Verify сравнивает пароль с хешем пользователя
+func (s *PasswordService) Verify(password, hash string) bool {
+	s, span := startSpan(s, s.ctx, "PasswordService.Verify")
+	defer span.End()
+
+	return s.hasher.Verify(password, hash)
+}
+
+// VerifyDummy выполняет проверку пароля с заранее созданным хешем
+// Так ответ для неизвестного email занимает столько же времени, сколько для существующего
+func (s *PasswordService) VerifyDummy(password string) {
+	s, span := startSpan(s, s.ctx, "PasswordService.VerifyDummy")
+	defer span.End()
+
+	s.dummy.once.Do(func() {
+		hash, err := s.hasher.Hash("dummy-password-for-timing")
+		if err != nil {
+			utils.LogError(s.ctx, "DummyPasswordHash", err)
+		}
+		s.dummy.hash = hash
+	})
+	s.hasher.Verify(password, s.dummy.hash)
+}
+
+// Rehash возвращает новый хеш, если текущий создан устаревшим алгоритмом или параметрами
+// Пароль должен быть уже проверен. Возвращает пустую строку, если пересчет не нужен
+func (s *PasswordService) Rehash(password, hash string) (string, error) {
+	s, span := startSpan(s, s.ctx, "PasswordService.Rehash")
+	defer span.End()
+
+	if !s.hasher.NeedsRehash(hash) {
+		return "", nil
+	}
+	return s.hasher.Hash(password)
+}
+
+// loadBreachedPasswords читает список утекших паролей, по одному на строку
+// Пустые строки и строки, начинающиеся с #, пропускаются
+func loadBreachedPasswords(path string) (map[string]struct{}, error) {
+	breached := make(map[string]struct{})
+	if path == "" {
+		return breached, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	return breached, nil
+}
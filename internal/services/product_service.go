@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrInvalidPrice    = errors.New("invalid price")
+)
+
+// ProductService содержит бизнес-логику для работы с каталогом товаров
+type ProductService struct {
+	productRepo *repository.ProductRepository
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+func NewProductService(productRepo *repository.ProductRepository) *ProductService {
+	return &ProductService{productRepo: productRepo}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *ProductService) WithContext(ctx context.Context) *ProductService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.productRepo = s.productRepo.WithContext(ctx)
+	return &scoped
+}
+
+// Create создает новый товар
+// Если активность не указана, товар сразу доступен для заказа
+func (s *ProductService) Create(req models.CreateProductRequest) (*models.Product, error) {
+	s, span := startSpan(s, s.ctx, "ProductService.Create")
+	defer span.End()
+
+	product := &models.Product{
+		Name:        req.Name,
+		Description: req.Description,
+		Currency:    req.Currency,
+		Stock:       req.Stock,
+		Active:      true,
+	}
+	if product.Currency == "" {
+		product.Currency = models.DefaultCurrency
+	}
+	price, err := parsePrice(req.Price, product.Currency)
+	if err != nil {
+		return nil, err
+	}
+	product.Price = price
+	if req.Active != nil {
+		product.Active = *req.Active
+	}
+
+	if err := s.productRepo.Create(product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// GetByID получает товар по ID
+// Неактивные товары возвращаются только если includeInactive = true
+func (s *ProductService) GetByID(id uint, includeInactive bool) (*models.Product, error) {
+	s, span := startSpan(s, s.ctx, "ProductService.GetByID")
+	defer span.End()
+
+	product, err := s.productRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	if !product.Active && !includeInactive {
+		return nil, ErrProductNotFound
+	}
+	return product, nil
+}
+
+// Update обновляет данные товара
+// Цена уже оформленных заказов не меняется - в них хранится снимок цены
+func (s *ProductService) Update(id uint, req models.UpdateProductRequest) (*models.Product, error) {
+	s, span := startSpan(s, s.ctx, "ProductService.Update")
+	defer span.End()
+
+	product, err := s.GetByID(id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		product.Name = req.Name
+	}
+	if req.Description != nil {
+		product.Description = *req.Description
+	}
+	// Цена разбирается в новой валюте. Без новой цены старая сумма в минимальных
+	// единицах получила бы другой смысл, поэтому смена валюты требует указать цену
+	if req.Currency != "" && req.Currency != product.Currency {
+		if req.Price == nil {
+			return nil, fmt.Errorf("%w: price is required when currency changes", ErrInvalidPrice)
+		}
+		product.Currency = req.Currency
+	}
+	if req.Price != nil {
+		price, err := parsePrice(*req.Price, product.Currency)
+		if err != nil {
+			return nil, err
+		}
+		product.Price = price
+	}
+	if req.Stock != nil {
+		product.Stock = *req.Stock
+	}
+	if req.Active != nil {
+		product.Active = *req.Active
+	}
+
+	if err := s.productRepo.Update(product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// Delete удаляет товар из каталога
+func (s *ProductService) Delete(id uint) error {
+	s, span := startSpan(s, s.ctx, "ProductService.Delete")
+	defer span.End()
+
+	if _, err := s.GetByID(id, true); err != nil {
+		return err
+	}
+	return s.productRepo.Delete(id)
+}
+
+// List возвращает страницу активных товаров
+// Поддерживает поиск по названию и описанию
+func (s *ProductService) List(params models.ProductListParams) (*models.Page[models.Product], error) {
+	s, span := startSpan(s, s.ctx, "ProductService.List")
+	defer span.End()
+
+	products, total, err := s.productRepo.List(params)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPage(products, params.Page, params.Limit, total), nil
+}
+
+// LowStock возвращает активные товары, остаток которых не превышает порог
+func (s *ProductService) LowStock(threshold int) ([]models.Product, error) {
+	s, span := startSpan(s, s.ctx, "ProductService.LowStock")
+	defer span.End()
+
+	return s.productRepo.ListLowStock(threshold)
+}
+
+// parsePrice разбирает цену товара в валюте currency
+// Валюта должна быть в справочнике ISO 4217, цена - положительной
+func parsePrice(amount models.Amount, currency string) (models.Money, error) {
+	price, err := amount.Money(currency)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidPrice, err)
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("%w: must be greater than zero", ErrInvalidPrice)
+	}
+	return price, nil
+}
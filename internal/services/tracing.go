@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+
+	"go-crud-api/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextual - сервис, который можно привязать к контексту запроса
+type contextual[S any] interface {
+	WithContext(ctx context.Context) S
+}
+
+// startSpan начинает span метода сервиса и возвращает сервис, привязанный к контексту span
+// Запросы к БД и вложенные сервисы, вызванные через возвращенный сервис, становятся дочерними span:
+//
+//	s, span := startSpan(s, s.ctx, "OrderService.Create")
+//	defer span.End()
+func startSpan[S contextual[S]](s S, ctx context.Context, name string) (S, trace.Span) {
+	ctx, span := tracing.Start(ctx, name)
+	return s.WithContext(ctx), span
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled      = errors.New("two-factor enrollment has not been started")
+	ErrInvalidTwoFactorCode      = errors.New("invalid two-factor code")
+	ErrInvalidTwoFactorChallenge = errors.New("invalid or expired mfa token")
+)
+
+const (
+	// recoveryCodeCount - количество кодов восстановления, выдаваемых за раз
+	recoveryCodeCount = 10
+	// maxTwoFactorAttempts - сколько раз можно ввести код по одному mfa-токену
+	maxTwoFactorAttempts = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactorService отвечает за двухфакторную аутентификацию по TOTP
+// Управляет подключением 2FA, кодами восстановления и вторым шагом входа.
+// Секреты TOTP хранятся в БД зашифрованными
+type TwoFactorService struct {
+	userRepo      *repository.UserRepository
+	twoFactorRepo *repository.TwoFactorRepository
+	secrets       *utils.SecretCipher
+	cfg           config.AuthConfig
+
+	ctx context.Context // Контекст запроса, задается WithContext
+}
+
+func NewTwoFactorService(userRepo *repository.UserRepository, twoFactorRepo *repository.TwoFactorRepository, secrets *utils.SecretCipher, cfg config.AuthConfig) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:      userRepo,
+		twoFactorRepo: twoFactorRepo,
+		secrets:       secrets,
+		cfg:           cfg,
+	}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *TwoFactorService) WithContext(ctx context.Context) *TwoFactorService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	scoped.twoFactorRepo = s.twoFactorRepo.WithContext(ctx)
+	return &scoped
+}
+
+// Enroll создает новый секрет TOTP для пользователя
+// 2FA включается только после подтверждения первым кодом через Confirm
+func (s *TwoFactorService) Enroll(userID uint) (*models.TwoFactorEnrollResponse, error) {
+	s, span := startSpan(s, s.ctx, "TwoFactorService.Enroll")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.secrets.Encrypt(secret, totpSecretAAD(user.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.SetSecret(user.ID, encrypted); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(s.cfg.TwoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm включает 2FA после проверки первого кода из приложения
+// Возвращает коды восстановления, которые показываются пользователю один раз
+func (s *TwoFactorService) Confirm(userID uint, code string) (*models.RecoveryCodesResponse, error) {
+	s, span := startSpan(s, s.ctx, "TwoFactorService.Confirm")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	secret, err := s.totpSecret(user)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.ValidateTOTP(secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	plain, codes, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.Enable(user.ID, step, codes); err != nil {
+		return nil, err
+	}
+
+	utils.LogOperation(s.ctx, "TwoFactorEnabled", user.ID, "Two-factor authentication enabled")
+	return &models.RecoveryCodesResponse{RecoveryCodes: plain}, nil
+}
+
+// Disable выключает 2FA после проверки кода из приложения или кода восстановления
+func (s *TwoFactorService) Disable(userID uint, code string) error {
+	s, span := startSpan(s, s.ctx, "TwoFactorService.Disable")
+	defer span.End()
+
+	user, err := s.enabledUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyCode(user, code); err != nil {
+		return err
+	}
+	if err := s.twoFactorRepo.Disable(user.ID); err != nil {
+		return err
+	}
+
+	utils.LogOperation(s.ctx, "TwoFactorDisabled", user.ID, "Two-factor authentication disabled")
+	return nil
+}
+
+// RegenerateRecoveryCodes выдает новый набор кодов восстановления
+// Старые коды перестают действовать. Требует актуальный код из приложения
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID uint, code string) (*models.RecoveryCodesResponse, error) {
+	s, span := startSpan(s, s.ctx, "TwoFactorService.RegenerateRecoveryCodes")
+	defer span.End()
+
+	user, err := s.enabledUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyTOTP(user, normalizeCode(code)); err != nil {
+		return nil, err
+	}
+
+	plain, codes, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(user.ID, codes); err != nil {
+		return nil, err
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: plain}, nil
+}
+
+// IsRequired проверяет, нужен ли пользователю второй шаг входа
+func (s *TwoFactorService) IsRequired(user *models.User) bool {
+	return user.TOTPEnabledAt != nil
+}
+
+// StartLogin выдает короткоживущий mfa-токен после успешной проверки пароля
+// Токен не дает доступа к API, его можно только обменять на пару токенов через CompleteLogin
+func (s *TwoFactorService) StartLogin(user *models.User) (*models.TwoFactorChallengeResponse, error) {
+	s, span := startSpan(s, s.ctx, "TwoFactorService.StartLogin")
+	defer span.End()
+
+	rawToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &models.TwoFactorChallenge{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(rawToken),
+		ExpiresAt: time.Now().Add(s.cfg.TwoFactorChallengeTTL),
+	}
+	if err := s.twoFactorRepo.CreateChallenge(challenge); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorChallengeResponse{
+		MFARequired: true,
+		MFAToken:    rawToken,
+		ExpiresIn:   int64(s.cfg.TwoFactorChallengeTTL.Seconds()),
+	}, nil
+}
+
+// CompleteLogin проверяет код для mfa-токена и возвращает пользователя
+// Количество попыток ввода кода по одному токену ограничено
+func (s *TwoFactorService) CompleteLogin(rawToken, code string) (*models.User, error) {
+	s, span := startSpan(s, s.ctx, "TwoFactorService.CompleteLogin")
+	defer span.End()
+
+	challenge, err := s.twoFactorRepo.GetChallengeByHash(utils.HashToken(rawToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidTwoFactorChallenge
+		}
+		return nil, err
+	}
+	if challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidTwoFactorChallenge
+	}
+
+	allowed, err := s.twoFactorRepo.RegisterAttempt(challenge.ID, maxTwoFactorAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrInvalidTwoFactorChallenge
+	}
+
+	user, err := s.enabledUser(challenge.UserID)
+	if err != nil {
+		return nil, ErrInvalidTwoFactorChallenge
+	}
+	if err := s.verifyCode(user, code); err != nil {
+		return nil, err
+	}
+
+	completed, err := s.twoFactorRepo.CompleteChallenge(challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !completed {
+		return nil, ErrInvalidTwoFactorChallenge
+	}
+
+	return user, nil
+}
+
+// enabledUser загружает пользователя и проверяет, что у него включена 2FA
+func (s *TwoFactorService) enabledUser(userID uint) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	return user, nil
+}
+
+// verifyCode проверяет код из приложения или код восстановления
+// Код восстановления после проверки становится недействительным
+func (s *TwoFactorService) verifyCode(user *models.User, code string) error {
+	code = normalizeCode(code)
+	if isTOTPCode(code) {
+		return s.verifyTOTP(user, code)
+	}
+
+	used, err := s.twoFactorRepo.UseRecoveryCode(user.ID, utils.HashToken(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+
+	utils.LogOperation(s.ctx, "RecoveryCodeUsed", user.ID, "Recovery code used")
+	return nil
+}
+
+// verifyTOTP проверяет код из приложения
+// Один и тот же код нельзя использовать дважды
+func (s *TwoFactorService) verifyTOTP(user *models.User, code string) error {
+	secret, err := s.totpSecret(user)
+	if err != nil {
+		return err
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := s.twoFactorRepo.UseTOTPStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// EncryptPlaintextSecrets шифрует секреты TOTP, сохраненные до появления шифрования
+// Вызывается при запуске. Возвращает количество зашифрованных секретов
+func (s *TwoFactorService) EncryptPlaintextSecrets() (int, error) {
+	s, span := startSpan(s, s.ctx, "TwoFactorService.EncryptPlaintextSecrets")
+	defer span.End()
+
+	users, err := s.twoFactorRepo.ListPlaintextSecrets()
+	if err != nil {
+		return 0, err
+	}
+
+	encrypted := 0
+	for _, user := range users {
+		value, err := s.secrets.Encrypt(user.TOTPSecret, totpSecretAAD(user.ID))
+		if err != nil {
+			return encrypted, err
+		}
+		// Секрет, который пользователь успел заменить, уже зашифрован
+		replaced, err := s.twoFactorRepo.ReplaceSecret(user.ID, user.TOTPSecret, value)
+		if err != nil {
+			return encrypted, err
+		}
+		if replaced {
+			encrypted++
+		}
+	}
+	return encrypted, nil
+}
+
+// totpSecret расшифровывает секрет TOTP пользователя
+// Секрет, сохраненный до появления шифрования, возвращается как есть
+func (s *TwoFactorService) totpSecret(user *models.User) (string, error) {
+	if !utils.IsEncrypted(user.TOTPSecret) {
+		return user.TOTPSecret, nil
+	}
+	secret, err := s.secrets.Decrypt(user.TOTPSecret, totpSecretAAD(user.ID))
+	if err != nil {
+		return "", fmt.Errorf("decrypt TOTP secret of user %d: %w", user.ID, err)
+	}
+	return secret, nil
+}
+
+// totpSecretAAD привязывает зашифрованный секрет TOTP к пользователю
+func totpSecretAAD(userID uint) string {
+	return "totp:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// newRecoveryCodes создает набор кодов восстановления
+// Возвращает коды для показа пользователю и записи с их хешами для БД
+func newRecoveryCodes(userID uint) ([]string, []models.RecoveryCode, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	codes := make([]models.RecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+
+		plain = append(plain, code[:5]+"-"+code[5:])
+		codes = append(codes, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(code),
+		})
+	}
+
+	return plain, codes, nil
+}
+
+// normalizeCode убирает пробелы и дефисы, которые пользователь мог ввести вместе с кодом
+func normalizeCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// isTOTPCode проверяет, похож ли код на код из приложения (6 цифр)
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// newTestTwoFactorService создает сервис 2FA с ключом шифрования для тестов
+func newTestTwoFactorService(t *testing.T) (*TwoFactorService, *gorm.DB) {
+	t.Helper()
+
+	db := newTestDB(t, &models.RecoveryCode{}, &models.TwoFactorChallenge{})
+	secrets, err := utils.NewSecretCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("create cipher: %v", err)
+	}
+
+	service := NewTwoFactorService(
+		repository.NewUserRepository(db).System(),
+		repository.NewTwoFactorRepository(repository.WithoutTenant(db)),
+		secrets,
+		config.AuthConfig{TwoFactorIssuer: "test", TwoFactorChallengeTTL: time.Minute},
+	)
+	return service.WithContext(context.Background()), db
+}
+
+// totpAt вычисляет код TOTP (SHA1, 6 цифр, шаг 30 секунд) на момент at
+func totpAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+// storedSecret возвращает секрет TOTP пользователя в том виде, в каком он лежит в БД
+func storedSecret(t *testing.T, db *gorm.DB, userID uint) string {
+	t.Helper()
+
+	user, err := repository.NewUserRepository(db).System().GetByID(userID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	return user.TOTPSecret
+}
+
+// enableTwoFactor подключает 2FA пользователю и возвращает секрет для приложения
+func enableTwoFactor(t *testing.T, service *TwoFactorService, userID uint) string {
+	t.Helper()
+
+	enrollment, err := service.Enroll(userID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if _, err := service.Confirm(userID, totpAt(t, enrollment.Secret, time.Now())); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	return enrollment.Secret
+}
+
+func TestTwoFactorSecretIsEncryptedAtRest(t *testing.T) {
+	service, db := newTestTwoFactorService(t)
+	user := createTestUser(t, db, "alice@example.com")
+
+	secret := enableTwoFactor(t, service, user.ID)
+
+	stored := storedSecret(t, db, user.ID)
+	if !utils.IsEncrypted(stored) || strings.Contains(stored, secret) {
+		t.Fatalf("TOTP secret is stored in plaintext: %q", stored)
+	}
+}
+
+func TestTwoFactorRejectsReplayedCode(t *testing.T) {
+	service, db := newTestTwoFactorService(t)
+	user := createTestUser(t, db, "alice@example.com")
+	secret := enableTwoFactor(t, service, user.ID)
+	now := time.Now()
+
+	// Код, которым подтверждено подключение, и более ранние коды уже использованы
+	for _, at := range []time.Time{now, now.Add(-30 * time.Second)} {
+		if _, err := service.RegenerateRecoveryCodes(user.ID, totpAt(t, secret, at)); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("code for %s: expected ErrInvalidTwoFactorCode, got %v", at.Format(time.TimeOnly), err)
+		}
+	}
+
+	// Код следующего шага принимается в пределах расхождения часов, но только один раз
+	next := totpAt(t, secret, now.Add(30*time.Second))
+	if _, err := service.RegenerateRecoveryCodes(user.ID, next); err != nil {
+		t.Fatalf("next step code: %v", err)
+	}
+	if _, err := service.RegenerateRecoveryCodes(user.ID, next); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("replayed code: expected ErrInvalidTwoFactorCode, got %v", err)
+	}
+}
+
+func TestTwoFactorSecretIsBoundToUser(t *testing.T) {
+	service, db := newTestTwoFactorService(t)
+	alice := createTestUser(t, db, "alice@example.com")
+	bob := createTestUser(t, db, "bob@example.com")
+	secret := enableTwoFactor(t, service, alice.ID)
+
+	// Зашифрованный секрет, скопированный в другую запись, не расшифровывается
+	err := repository.WithoutTenant(db).Model(&models.User{}).Where("id = ?", bob.ID).
+		Updates(map[string]interface{}{"totp_secret": storedSecret(t, db, alice.ID), "totp_enabled_at": time.Now()}).Error
+	if err != nil {
+		t.Fatalf("copy secret: %v", err)
+	}
+
+	code := totpAt(t, secret, time.Now().Add(30*time.Second))
+	if _, err := service.RegenerateRecoveryCodes(bob.ID, code); !errors.Is(err, utils.ErrInvalidCiphertext) {
+		t.Fatalf("expected ErrInvalidCiphertext, got %v", err)
+	}
+}
+
+func TestTwoFactorEncryptsPlaintextSecrets(t *testing.T) {
+	service, db := newTestTwoFactorService(t)
+	user := createTestUser(t, db, "alice@example.com")
+
+	// Секрет, сохраненный до появления шифрования
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	err = repository.WithoutTenant(db).Model(&models.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled_at": time.Now()}).Error
+	if err != nil {
+		t.Fatalf("store plaintext secret: %v", err)
+	}
+
+	now := time.Now()
+	if _, err := service.RegenerateRecoveryCodes(user.ID, totpAt(t, secret, now)); err != nil {
+		t.Fatalf("plaintext secret is not accepted: %v", err)
+	}
+
+	count, err := service.EncryptPlaintextSecrets()
+	if err != nil {
+		t.Fatalf("encrypt secrets: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 encrypted secret, got %d", count)
+	}
+	if stored := storedSecret(t, db, user.ID); !utils.IsEncrypted(stored) {
+		t.Fatalf("secret is still stored in plaintext: %q", stored)
+	}
+	if count, err := service.EncryptPlaintextSecrets(); err != nil || count != 0 {
+		t.Fatalf("second run: %d, %v", count, err)
+	}
+
+	// Тот же секрет продолжает работать, защита от повтора сохраняется
+	if _, err := service.RegenerateRecoveryCodes(user.ID, totpAt(t, secret, now)); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("replayed code: expected ErrInvalidTwoFactorCode, got %v", err)
+	}
+	if _, err := service.RegenerateRecoveryCodes(user.ID, totpAt(t, secret, now.Add(30*time.Second))); err != nil {
+		t.Fatalf("encrypted secret is not accepted: %v", err)
+	}
+}
@@ -1,174 +1,472 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/metrics"
 	"go-crud-api/internal/models"
 	"go-crud-api/internal/repository"
 	"go-crud-api/internal/utils"
 
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+var (
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrRoleChangeForbidden  = errors.New("only a superadmin can manage superadmin accounts")
+
+	// ErrEmailUnavailable не уточняет, в какой организации занят email,
+	// чтобы по регистрации нельзя было узнать об учетных записях других организаций
+	ErrEmailUnavailable = errors.New("this email cannot be used")
+)
+
+// LoginThrottledError возвращается, когда вход временно запрещен из-за неудачных попыток
+// RetryAfter - через сколько можно повторить попытку
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many failed login attempts"
+}
+
 // UserService содержит бизнес-логику для работы с пользователями
-// Включает валидацию данных и обработку ошибок
+// Включает валидацию данных и обработку ошибок.
+// Операции с пользователями организации выполняются через ForOrg, вход - на уровне системы
 type UserService struct {
-	userRepo *repository.UserRepository
+	userRepo    *repository.UserRepository
+	orgRepo     *repository.OrganizationRepository
+	orgID       uint
+	attemptRepo *repository.LoginAttemptRepository
+	tokenRepo   *repository.TokenRepository
+	passwords   *PasswordService
+	cursorCodec *utils.CursorCodec
+	authCfg     config.AuthConfig
+
+	ctx context.Context // Контекст запроса, задается WithContext
 }
 
-func NewUserService(userRepo *repository.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+func NewUserService(userRepo *repository.UserRepository, orgRepo *repository.OrganizationRepository, attemptRepo *repository.LoginAttemptRepository, tokenRepo *repository.TokenRepository, passwords *PasswordService, cursorCodec *utils.CursorCodec, authCfg config.AuthConfig) *UserService {
+	return &UserService{
+		userRepo:    userRepo,
+		orgRepo:     orgRepo,
+		attemptRepo: attemptRepo,
+		tokenRepo:   tokenRepo,
+		passwords:   passwords,
+		cursorCodec: cursorCodec,
+		authCfg:     authCfg,
+	}
+}
+
+// WithContext возвращает сервис, работающий в контексте запроса ctx
+func (s *UserService) WithContext(ctx context.Context) *UserService {
+	scoped := *s
+	scoped.ctx = ctx
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	scoped.orgRepo = s.orgRepo.WithContext(ctx)
+	scoped.attemptRepo = s.attemptRepo.WithContext(ctx)
+	scoped.tokenRepo = s.tokenRepo.WithContext(ctx)
+	scoped.passwords = s.passwords.WithContext(ctx)
+	return &scoped
+}
+
+// ForOrg возвращает сервис, работающий только с пользователями организации orgID
+func (s *UserService) ForOrg(orgID uint) *UserService {
+	scoped := *s
+	scoped.userRepo = s.userRepo.ForOrg(orgID)
+	scoped.orgID = orgID
+	return &scoped
 }
 
 func (s *UserService) Register(user *models.User) error {
+	s, span := startSpan(s, s.ctx, "UserService.Register")
+	defer span.End()
+
 	// Проверяем, существует ли пользователь с таким email
 	existingUser, err := s.userRepo.GetByEmail(user.Email)
 	if err == nil && existingUser != nil {
-		return errors.New("user with this email already exists")
+		return ErrEmailUnavailable
 	}
 
 	// Хешируем пароль
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.PasswordHash), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwords.HashNew(user.PasswordHash)
 	if err != nil {
 		return err
 	}
-	user.PasswordHash = string(hashedPassword)
+	user.PasswordHash = hashedPassword
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
 
 	// Создаем пользователя
 	return s.userRepo.Create(user)
 }
 
 // Login аутентифицирует пользователя
-// Проверяет email и пароль, возвращает пользователя
-func (s *UserService) Login(email, password string) (*models.User, error) {
-	user, err := s.userRepo.GetByEmail(email)
+// Проверяет email и пароль, возвращает пользователя.
+// Неудачные попытки учитываются по email и по IP: после LoginDelayAfter неудач
+// включается нарастающая задержка, после LoginMaxFailures - временная блокировка.
+// Для незарегистрированного email поведение и время ответа такие же, как для существующего
+func (s *UserService) Login(email, password, ip string) (user *models.User, err error) {
+	s, span := startSpan(s, s.ctx, "UserService.Login")
+	defer span.End()
+
+	defer func() {
+		metrics.LoginsTotal.WithLabelValues(loginResult(err)).Inc()
+	}()
+
+	key := strings.ToLower(strings.TrimSpace(email))
+	now := time.Now()
+
+	ipFailures, err := s.attemptRepo.CountByIP(ip, now.Add(-s.authCfg.LoginFailureWindow))
+	if err != nil {
+		return nil, err
+	}
+	if s.authCfg.LoginIPMaxFailures > 0 && ipFailures >= int64(s.authCfg.LoginIPMaxFailures) {
+		return nil, &LoginThrottledError{RetryAfter: s.authCfg.LoginFailureWindow}
+	}
+
+	attempts, err := s.attemptRepo.RecentByEmail(key, now.Add(-s.authCfg.LoginFailureWindow-s.authCfg.LoginLockoutDuration))
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		return nil, err
+	}
+	if until := s.lockedUntil(attempts); until != nil && until.After(now) {
+		return nil, &LoginThrottledError{RetryAfter: until.Sub(now)}
+	}
+
+	// Организация пользователя определяется по email, поэтому поиск не ограничен организацией
+	users := s.userRepo.System()
+	user, err = users.GetByEmail(email)
+	if err != nil {
+		s.passwords.VerifyDummy(password)
+		return nil, s.registerFailure(nil, key, ip, attempts)
+	}
+
+	if !s.passwords.Verify(password, user.PasswordHash) {
+		return nil, s.registerFailure(user, key, ip, attempts)
+	}
+
+	// Успешный вход сбрасывает счетчик неудач учетной записи
+	if err := s.attemptRepo.ClearEmail(key); err != nil {
+		return nil, err
+	}
+	if user.LockedUntil != nil {
+		if err := users.SetLockedUntil(user.ID, nil); err != nil {
+			return nil, err
+		}
+		user.LockedUntil = nil
+	}
+
+	// Хеш, созданный прежним алгоритмом или с прежними параметрами, пересчитываем,
+	// пока известен пароль. Ошибка пересчета не мешает входу
+	if hash, err := s.passwords.Rehash(password, user.PasswordHash); err != nil {
+		utils.LogError(s.ctx, "PasswordRehash", err)
+	} else if hash != "" {
+		if err := users.UpdatePasswordHash(user.ID, hash); err != nil {
+			utils.LogError(s.ctx, "PasswordRehash", err)
+		} else {
+			user.PasswordHash = hash
+			utils.LogOperation(s.ctx, "PasswordRehash", user.ID, "Password hash upgraded")
+		}
 	}
 
-	if !utils.CheckPasswordHash(password, user.PasswordHash) {
-		return nil, errors.New("invalid email or password")
+	// Заодно чистим записи, которые больше не нужны
+	if err := s.attemptRepo.DeleteOlderThan(now.Add(-s.authCfg.LoginFailureWindow - s.authCfg.LoginLockoutDuration)); err != nil {
+		utils.LogError(s.ctx, "LoginAttemptsCleanup", err)
 	}
 
 	return user, nil
 }
 
+// Unlock снимает блокировку входа и сбрасывает счетчик неудачных попыток
+// Доступно только администраторам
+func (s *UserService) Unlock(id uint) (*models.UserResponse, error) {
+	s, span := startSpan(s, s.ctx, "UserService.Unlock")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attemptRepo.ClearEmail(strings.ToLower(user.Email)); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetLockedUntil(user.ID, nil); err != nil {
+		return nil, err
+	}
+	user.LockedUntil = nil
+
+	utils.LogOperation(s.ctx, "UnlockUser", user.ID, "Login lockout cleared")
+	return newUserResponse(user), nil
+}
+
+// loginResult возвращает значение метки result для метрики входов
+func loginResult(err error) string {
+	var throttled *LoginThrottledError
+	switch {
+	case err == nil:
+		return metrics.LoginSucceeded
+	case errors.Is(err, ErrInvalidCredentials):
+		return metrics.LoginFailed
+	case errors.As(err, &throttled):
+		return metrics.LoginThrottled
+	default:
+		return metrics.LoginError
+	}
+}
+
+// registerFailure сохраняет неудачную попытку входа и при необходимости блокирует учетную запись
+// attempts - ранее сохраненные попытки для email, от новых к старым
+func (s *UserService) registerFailure(user *models.User, key, ip string, attempts []models.LoginAttempt) error {
+	attempt := &models.LoginAttempt{Email: key, IP: ip}
+	if err := s.attemptRepo.Create(attempt); err != nil {
+		return err
+	}
+
+	if user != nil {
+		attempts = append([]models.LoginAttempt{*attempt}, attempts...)
+		if until := s.lockedUntil(attempts); until != nil {
+			if err := s.userRepo.System().SetLockedUntil(user.ID, until); err != nil {
+				return err
+			}
+		}
+		utils.LogOperation(s.ctx, "LoginFailed", user.ID, "Failed login attempt from "+ip)
+	}
+
+	return ErrInvalidCredentials
+}
+
+// lockedUntil вычисляет, до какого времени вход запрещен после серии неудачных попыток
+// Учитываются неудачи за LoginFailureWindow до последней из них.
+// Возвращает nil если ограничений нет
+func (s *UserService) lockedUntil(attempts []models.LoginAttempt) *time.Time {
+	if len(attempts) == 0 {
+		return nil
+	}
+
+	last := attempts[0].CreatedAt
+	failures := 0
+	for _, attempt := range attempts {
+		if attempt.CreatedAt.After(last.Add(-s.authCfg.LoginFailureWindow)) {
+			failures++
+		}
+	}
+
+	var delay time.Duration
+	switch {
+	case s.authCfg.LoginMaxFailures > 0 && failures >= s.authCfg.LoginMaxFailures:
+		delay = s.authCfg.LoginLockoutDuration
+	case failures >= s.authCfg.LoginDelayAfter:
+		// Задержка удваивается с каждой неудачей: 1s, 2s, 4s...
+		shift := failures - s.authCfg.LoginDelayAfter
+		if shift > 16 {
+			shift = 16
+		}
+		delay = time.Second << shift
+		if delay > s.authCfg.LoginLockoutDuration {
+			delay = s.authCfg.LoginLockoutDuration
+		}
+	default:
+		return nil
+	}
+
+	until := last.Add(delay)
+	return &until
+}
+
 func (s *UserService) GetByID(id uint) (*models.User, error) {
+	s, span := startSpan(s, s.ctx, "UserService.GetByID")
+	defer span.End()
+
 	return s.userRepo.GetByID(id)
 }
 
 func (s *UserService) Update(user *models.User) error {
+	s, span := startSpan(s, s.ctx, "UserService.Update")
+	defer span.End()
+
 	return s.userRepo.Update(user)
 }
 
 func (s *UserService) Delete(id uint) error {
+	s, span := startSpan(s, s.ctx, "UserService.Delete")
+	defer span.End()
+
 	return s.userRepo.Delete(id)
 }
 
 // CreateUser создает нового пользователя
-// Проверяет уникальность email и хеширует пароль
+// Проверяет организацию, уникальность email, политику паролей и хеширует пароль.
+// Пользователь создается в организации сервиса (ForOrg)
 func (s *UserService) CreateUser(req models.CreateUserRequest) (*models.UserResponse, error) {
+	s, span := startSpan(s, s.ctx, "UserService.CreateUser")
+	defer span.End()
+
+	if _, err := s.orgRepo.GetByID(s.orgID); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+
 	exists, err := s.userRepo.CheckExists(req.Email)
 	if err != nil {
 		return nil, err
 	}
 	if exists {
-		return nil, errors.New("user with this email already exists")
+		return nil, ErrEmailUnavailable
 	}
 
-	hashedPassword, err := utils.HashPassword(req.Password)
+	hashedPassword, err := s.passwords.HashNew(req.Password)
 	if err != nil {
 		return nil, err
 	}
 
 	user := &models.User{
+		OrgID:        s.orgID,
 		Name:         req.Name,
 		Email:        req.Email,
 		Age:          req.Age,
 		PasswordHash: hashedPassword,
+		Role:         models.RoleUser,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, err
 	}
 
-	return &models.UserResponse{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		Age:       user.Age,
-		CreatedAt: user.CreatedAt,
-	}, nil
+	metrics.UserRegistrationsTotal.Inc()
+	return newUserResponse(user), nil
 }
 
 // GetUser получает информацию о пользователе по ID
 // Возвращает ошибку если пользователь не найден
 func (s *UserService) GetUser(id uint) (*models.UserResponse, error) {
+	s, span := startSpan(s, s.ctx, "UserService.GetUser")
+	defer span.End()
+
 	user, err := s.userRepo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.UserResponse{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		Age:       user.Age,
-		CreatedAt: user.CreatedAt,
-	}, nil
+	return newUserResponse(user), nil
 }
 
 // UpdateUser обновляет данные пользователя
-// Проверяет существование пользователя и валидирует данные
-func (s *UserService) UpdateUser(id uint, req models.UpdateUserRequest) (*models.UserResponse, error) {
+// Проверяет существование пользователя и валидирует данные.
+// Учетную запись superadmin меняет только пользователь с ролью actorRole = superadmin.
+// При смене пароля завершаются все сессии пользователя, кроме currentSessionID
+func (s *UserService) UpdateUser(id uint, req models.UpdateUserRequest, actorRole, currentSessionID string) (*models.UserResponse, error) {
+	s, span := startSpan(s, s.ctx, "UserService.UpdateUser")
+	defer span.End()
+
 	user, err := s.userRepo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
+	if user.Role == models.RoleSuperAdmin && actorRole != models.RoleSuperAdmin {
+		return nil, ErrRoleChangeForbidden
+	}
 
 	if req.Name != "" {
 		user.Name = req.Name
 	}
-	if req.Email != "" {
+	if req.Email != "" && req.Email != user.Email {
 		exists, err := s.userRepo.CheckExists(req.Email)
 		if err != nil {
 			return nil, err
 		}
 		if exists {
-			return nil, errors.New("user with this email already exists")
+			return nil, ErrEmailUnavailable
 		}
+		// Новый адрес нужно подтвердить заново
 		user.Email = req.Email
+		user.EmailVerifiedAt = nil
 	}
 	if req.Age != 0 {
 		user.Age = req.Age
 	}
-	if req.Password != "" {
-		hashedPassword, err := utils.HashPassword(req.Password)
-		if err != nil {
-			return nil, err
+
+	// Пароль, запись в истории паролей и отзыв сессий сохраняются вместе
+	err = s.userRepo.Transaction(func(tx *gorm.DB) error {
+		if req.Password != "" {
+			if err := s.passwords.WithTx(tx).SetPassword(user, req.Password); err != nil {
+				return err
+			}
 		}
-		user.PasswordHash = hashedPassword
+
+		if err := s.userRepo.WithTx(tx).Update(user); err != nil {
+			return err
+		}
+
+		// Если пароль меняют из-за утечки, злоумышленник теряет доступ через ранее открытые сессии
+		if req.Password != "" {
+			return s.tokenRepo.WithTx(tx).RevokeOtherUserSessions(user.ID, currentSessionID, "password_change")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.userRepo.Update(user); err != nil {
+	return newUserResponse(user), nil
+}
+
+// UpdateRole меняет роль пользователя
+// При смене роли все сессии пользователя завершаются: access-токены содержат роль,
+// и после понижения прежние права не должны действовать до истечения токенов.
+// Роль superadmin выдает и снимает только пользователь с ролью actorRole = superadmin
+func (s *UserService) UpdateRole(id uint, role, actorRole string) (*models.UserResponse, error) {
+	s, span := startSpan(s, s.ctx, "UserService.UpdateRole")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
 		return nil, err
 	}
 
-	return &models.UserResponse{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		Age:       user.Age,
-		CreatedAt: user.CreatedAt,
-	}, nil
+	if (role == models.RoleSuperAdmin || user.Role == models.RoleSuperAdmin) && actorRole != models.RoleSuperAdmin {
+		return nil, ErrRoleChangeForbidden
+	}
+
+	if user.Role == role {
+		return newUserResponse(user), nil
+	}
+
+	user.Role = role
+	err = s.userRepo.Transaction(func(tx *gorm.DB) error {
+		if err := s.userRepo.WithTx(tx).Update(user); err != nil {
+			return err
+		}
+		return s.tokenRepo.WithTx(tx).RevokeUserSessions(user.ID, "role_change")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newUserResponse(user), nil
 }
 
 // DeleteUser удаляет пользователя по ID
-// Возвращает ошибку если пользователь не найден
-func (s *UserService) DeleteUser(id uint) error {
-	_, err := s.userRepo.GetByID(id)
+// Возвращает ошибку если пользователь не найден.
+// Учетную запись superadmin удаляет только пользователь с ролью actorRole = superadmin
+func (s *UserService) DeleteUser(id uint, actorRole string) error {
+	s, span := startSpan(s, s.ctx, "UserService.DeleteUser")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(id)
 	if err != nil {
 		return err
 	}
+	if user.Role == models.RoleSuperAdmin && actorRole != models.RoleSuperAdmin {
+		return ErrRoleChangeForbidden
+	}
 
 	return s.userRepo.Delete(id)
 }
@@ -176,6 +474,9 @@ func (s *UserService) DeleteUser(id uint) error {
 // ListUsers возвращает список пользователей с пагинацией
 // Поддерживает фильтрацию и сортировку
 func (s *UserService) ListUsers(params models.PaginationParams) (*models.PaginatedResponse, error) {
+	s, span := startSpan(s, s.ctx, "UserService.ListUsers")
+	defer span.End()
+
 	users, total, err := s.userRepo.List(params)
 	if err != nil {
 		return nil, err
@@ -183,13 +484,7 @@ func (s *UserService) ListUsers(params models.PaginationParams) (*models.Paginat
 
 	userResponses := make([]models.UserResponse, len(users))
 	for i, user := range users {
-		userResponses[i] = models.UserResponse{
-			ID:        user.ID,
-			Name:      user.Name,
-			Email:     user.Email,
-			Age:       user.Age,
-			CreatedAt: user.CreatedAt,
-		}
+		userResponses[i] = *newUserResponse(&user)
 	}
 
 	totalPages := (int(total) + params.Limit - 1) / params.Limit
@@ -203,28 +498,60 @@ func (s *UserService) ListUsers(params models.PaginationParams) (*models.Paginat
 	}, nil
 }
 
-func (s *UserService) CreateOrder(userID uint, req models.CreateOrderRequest) (*models.Order, error) {
-	// Проверяем существование пользователя
-	_, err := s.userRepo.GetByID(userID)
+// ListUsersByCursor возвращает страницу пользователей с keyset-пагинацией
+// Пользователи отсортированы по (created_at, id) от новых к старым
+func (s *UserService) ListUsersByCursor(params models.PaginationParams) (*models.CursorPage[models.UserResponse], error) {
+	s, span := startSpan(s, s.ctx, "UserService.ListUsersByCursor")
+	defer span.End()
+
+	var after *utils.Cursor
+	if params.Cursor != "" {
+		cursor, err := s.cursorCodec.Decode(params.Cursor, userCursorScope(params))
+		if err != nil {
+			return nil, err
+		}
+		after = cursor
+	}
+
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	users, err := s.userRepo.ListAfter(params, after, params.Limit+1)
 	if err != nil {
 		return nil, err
 	}
 
-	order := &models.Order{
-		UserID:   userID,
-		Product:  req.Product,
-		Quantity: req.Quantity,
-		Price:    req.Price,
+	page := &models.CursorPage[models.UserResponse]{
+		Items: make([]models.UserResponse, 0, params.Limit),
+		Limit: params.Limit,
+	}
+	if len(users) > params.Limit {
+		users = users[:params.Limit]
+		last := users[len(users)-1]
+		page.NextCursor, err = s.cursorCodec.Encode(utils.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, userCursorScope(params))
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if err := s.userRepo.CreateOrder(order); err != nil {
-		return nil, err
+	for i := range users {
+		page.Items = append(page.Items, *newUserResponse(&users[i]))
 	}
+	return page, nil
+}
 
-	return order, nil
+// userCursorScope описывает выборку пользователей, к которой привязан курсор:
+// фильтры и порядок keyset-пагинации. Размер страницы можно менять между запросами
+func userCursorScope(params models.PaginationParams) string {
+	return "users?" + url.Values{
+		"min_age": {strconv.Itoa(params.MinAge)},
+		"max_age": {strconv.Itoa(params.MaxAge)},
+		"sort":    {keysetSort},
+	}.Encode()
 }
 
 func (s *UserService) GetUserOrders(userID uint) ([]models.Order, error) {
+	s, span := startSpan(s, s.ctx, "UserService.GetUserOrders")
+	defer span.End()
+
 	// Проверяем существование пользователя
 	_, err := s.userRepo.GetByID(userID)
 	if err != nil {
@@ -236,11 +563,17 @@ func (s *UserService) GetUserOrders(userID uint) ([]models.Order, error) {
 
 // GetUsers возвращает список пользователей с пагинацией
 func (s *UserService) GetUsers(params models.PaginationParams) ([]models.User, int64, error) {
+	s, span := startSpan(s, s.ctx, "UserService.GetUsers")
+	defer span.End()
+
 	return s.userRepo.List(params)
 }
 
 // GetUsersWithOrders возвращает список пользователей с их заказами
 func (s *UserService) GetUsersWithOrders(params models.PaginationParams) ([]models.User, int64, error) {
+	s, span := startSpan(s, s.ctx, "UserService.GetUsersWithOrders")
+	defer span.End()
+
 	return s.userRepo.List(params)
 }
 
@@ -249,3 +582,19 @@ func (s *UserService) SomeOtherMethod() ([]models.User, int64, error) {
 	params := models.PaginationParams{Page: 1, Limit: 10}
 	return s.GetUsers(params)
 }
+
+// newUserResponse формирует ответ API без конфиденциальных данных пользователя
+func newUserResponse(user *models.User) *models.UserResponse {
+	return &models.UserResponse{
+		ID:               user.ID,
+		OrgID:            user.OrgID,
+		Name:             user.Name,
+		Email:            user.Email,
+		Age:              user.Age,
+		Role:             user.Role,
+		EmailVerifiedAt:  user.EmailVerifiedAt,
+		TwoFactorEnabled: user.TOTPEnabledAt != nil,
+		LockedUntil:      user.LockedUntil,
+		CreatedAt:        user.CreatedAt,
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-crud-api/internal/config"
+	"go-crud-api/internal/models"
+	"go-crud-api/internal/repository"
+	"go-crud-api/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// newTestUserService создает сервис пользователей организации по умолчанию
+func newTestUserService(t *testing.T) (*UserService, *gorm.DB) {
+	t.Helper()
+
+	db := newTestDB(t, &models.PasswordHistory{}, &models.LoginAttempt{}, &models.Session{}, &models.RefreshToken{})
+	service := NewUserService(
+		repository.NewUserRepository(db),
+		repository.NewOrganizationRepository(db),
+		repository.NewLoginAttemptRepository(db),
+		repository.NewTokenRepository(db),
+		newTestPasswordService(t, db),
+		utils.NewCursorCodec("secret"),
+		config.AuthConfig{},
+	)
+	return service.WithContext(context.Background()).ForOrg(models.DefaultOrgID), db
+}
+
+// createTestUserWithRole создает пользователя организации по умолчанию с ролью role
+func createTestUserWithRole(t *testing.T, db *gorm.DB, email, role string) *models.User {
+	t.Helper()
+
+	user := createTestUser(t, db, email)
+	if err := repository.WithoutTenant(db).Model(user).Update("role", role).Error; err != nil {
+		t.Fatalf("set role: %v", err)
+	}
+	return user
+}
+
+func TestUpdateUserProtectsSuperAdmin(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       models.UpdateUserRequest
+		actorRole string
+		wantErr   error
+	}{
+		{"admin changes email", models.UpdateUserRequest{Email: "attacker@example.com"}, models.RoleAdmin, ErrRoleChangeForbidden},
+		{"admin changes password", models.UpdateUserRequest{Password: "attacker-password"}, models.RoleAdmin, ErrRoleChangeForbidden},
+		{"admin changes name", models.UpdateUserRequest{Name: "Mallory"}, models.RoleAdmin, ErrRoleChangeForbidden},
+		{"support changes email", models.UpdateUserRequest{Email: "attacker@example.com"}, models.RoleSupport, ErrRoleChangeForbidden},
+		{"superadmin changes email", models.UpdateUserRequest{Email: "root@example.com"}, models.RoleSuperAdmin, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, db := newTestUserService(t)
+			target := createTestUserWithRole(t, db, "owner@example.com", models.RoleSuperAdmin)
+
+			_, err := service.UpdateUser(target.ID, tt.req, tt.actorRole, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+
+			stored, err := repository.NewUserRepository(db).System().GetByID(target.ID)
+			if err != nil {
+				t.Fatalf("get user: %v", err)
+			}
+			changed := stored.Email != target.Email || stored.Name != target.Name || stored.PasswordHash != target.PasswordHash
+			if changed != (tt.wantErr == nil) {
+				t.Fatalf("account changed: %v, want %v", changed, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestUpdateUserByAdmin(t *testing.T) {
+	service, db := newTestUserService(t)
+	target := createTestUser(t, db, "alice@example.com")
+
+	user, err := service.UpdateUser(target.ID, models.UpdateUserRequest{Email: "alice@new.example"}, models.RoleAdmin, "")
+	if err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	if user.Email != "alice@new.example" {
+		t.Fatalf("email was not changed: %q", user.Email)
+	}
+}
+
+func TestDeleteUserProtectsSuperAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		targetRole string
+		actorRole  string
+		wantErr    error
+	}{
+		{"admin deletes superadmin", models.RoleSuperAdmin, models.RoleAdmin, ErrRoleChangeForbidden},
+		{"user deletes superadmin", models.RoleSuperAdmin, models.RoleUser, ErrRoleChangeForbidden},
+		{"superadmin deletes superadmin", models.RoleSuperAdmin, models.RoleSuperAdmin, nil},
+		{"admin deletes admin", models.RoleAdmin, models.RoleAdmin, nil},
+		{"admin deletes user", models.RoleUser, models.RoleAdmin, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, db := newTestUserService(t)
+			target := createTestUserWithRole(t, db, "target@example.com", tt.targetRole)
+
+			if err := service.DeleteUser(target.ID, tt.actorRole); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+
+			_, err := repository.NewUserRepository(db).System().GetByID(target.ID)
+			if deleted := err != nil; deleted != (tt.wantErr == nil) {
+				t.Fatalf("user deleted: %v, want %v (%v)", deleted, tt.wantErr == nil, err)
+			}
+		})
+	}
+}
+
+// createTestSessions создает пользователю сессии с ID ids, у каждой по refresh-токену
+func createTestSessions(t *testing.T, db *gorm.DB, userID uint, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		fixtures := []interface{}{
+			&models.Session{ID: id, UserID: userID, LastSeenAt: time.Now()},
+			&models.RefreshToken{SessionID: id, UserID: userID, TokenHash: "hash-" + id, ExpiresAt: time.Now().Add(time.Hour)},
+		}
+		for _, fixture := range fixtures {
+			if err := db.Create(fixture).Error; err != nil {
+				t.Fatalf("create fixture: %v", err)
+			}
+		}
+	}
+}
+
+func TestUpdateUserPasswordRevokesOtherSessions(t *testing.T) {
+	service, db := newTestUserService(t)
+	user := createTestUser(t, db, "alice@example.com")
+	createTestSessions(t, db, user.ID, "current", "laptop", "phone")
+
+	if _, err := service.UpdateUser(user.ID, models.UpdateUserRequest{Password: "new-password"}, models.RoleUser, "current"); err != nil {
+		t.Fatalf("update password: %v", err)
+	}
+
+	if n := countRows(t, db, &models.PasswordHistory{}, "user_id = ?", user.ID); n != 1 {
+		t.Fatalf("expected previous password in history, got %d entries", n)
+	}
+	if n := countRows(t, db, &models.Session{}, "revoked_at IS NULL AND id <> ?", "current"); n != 0 {
+		t.Fatalf("other sessions survived the password change: %d", n)
+	}
+	if n := countRows(t, db, &models.RefreshToken{}, "revoked_at IS NULL AND session_id <> ?", "current"); n != 0 {
+		t.Fatalf("refresh tokens of other sessions survived the password change: %d", n)
+	}
+	if n := countRows(t, db, &models.Session{}, "revoked_at IS NULL AND id = ?", "current"); n != 1 {
+		t.Fatal("current session was revoked")
+	}
+
+	// Без смены пароля сессии не отзываются
+	createTestSessions(t, db, user.ID, "tablet")
+	if _, err := service.UpdateUser(user.ID, models.UpdateUserRequest{Name: "Alice"}, models.RoleUser, "current"); err != nil {
+		t.Fatalf("update name: %v", err)
+	}
+	if n := countRows(t, db, &models.Session{}, "revoked_at IS NULL AND id = ?", "tablet"); n != 1 {
+		t.Fatal("session was revoked although the password was not changed")
+	}
+}
+
+func TestUpdateUserPasswordRollsBackOnFailure(t *testing.T) {
+	service, db := newTestUserService(t)
+	user := createTestUser(t, db, "alice@example.com")
+	createTestSessions(t, db, user.ID, "laptop")
+
+	errUpdateFailed := errors.New("update failed")
+	err := db.Callback().Update().Before("gorm:update").Register("test:fail_user_update", func(tx *gorm.DB) {
+		if tx.Statement.Schema != nil && tx.Statement.Schema.Table == "users" {
+			tx.AddError(errUpdateFailed)
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	if _, err := service.UpdateUser(user.ID, models.UpdateUserRequest{Password: "new-password"}, models.RoleUser, ""); !errors.Is(err, errUpdateFailed) {
+		t.Fatalf("expected update error, got %v", err)
+	}
+
+	if n := countRows(t, db, &models.PasswordHistory{}, "user_id = ?", user.ID); n != 0 {
+		t.Fatalf("stale password history entries: %d", n)
+	}
+	if n := countRows(t, db, &models.Session{}, "revoked_at IS NULL"); n != 1 {
+		t.Fatal("sessions were revoked although the password was not changed")
+	}
+}
+
+func TestUpdateRoleRevokesSessions(t *testing.T) {
+	service, db := newTestUserService(t)
+	user := createTestUserWithRole(t, db, "alice@example.com", models.RoleAdmin)
+	createTestSessions(t, db, user.ID, "laptop", "phone")
+
+	// Та же роль ничего не меняет
+	if _, err := service.UpdateRole(user.ID, models.RoleAdmin, models.RoleAdmin); err != nil {
+		t.Fatalf("update role: %v", err)
+	}
+	if n := countRows(t, db, &models.Session{}, "revoked_at IS NULL"); n != 2 {
+		t.Fatalf("sessions were revoked although the role was not changed: %d left", n)
+	}
+
+	updated, err := service.UpdateRole(user.ID, models.RoleUser, models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("update role: %v", err)
+	}
+	if updated.Role != models.RoleUser {
+		t.Fatalf("role %s, want %s", updated.Role, models.RoleUser)
+	}
+	if n := countRows(t, db, &models.Session{}, "revoked_at IS NULL"); n != 0 {
+		t.Fatalf("sessions with the old role survived: %d", n)
+	}
+	if n := countRows(t, db, &models.RefreshToken{}, "revoked_at IS NULL"); n != 0 {
+		t.Fatalf("refresh tokens with the old role survived: %d", n)
+	}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor возвращается для поврежденного или подделанного курсора
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor указывает на последнюю запись страницы при keyset-пагинации
+// Следующая страница начинается сразу после записи с этими (created_at, id)
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"id"`
+}
+
+// CursorCodec кодирует курсоры в непрозрачные строки и подписывает их HMAC,
+// чтобы клиент не мог подменить позицию в выборке.
+// Подпись включает scope - описание выборки (список и его фильтры), поэтому курсор
+// одного списка не принимается другим
+type CursorCodec struct {
+	secret []byte
+}
+
+func NewCursorCodec(secret string) *CursorCodec {
+	return &CursorCodec{secret: []byte(secret)}
+}
+
+// Encode превращает курсор выборки scope в строку вида <payload>.<signature>
+func (c *CursorCodec) Encode(cursor Cursor, scope string) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + c.sign(scope, encoded), nil
+}
+
+// Decode проверяет подпись и разбирает курсор
+// Курсор, выданный для другой выборки, считается недействительным
+func (c *CursorCodec) Decode(value, scope string) (*Cursor, error) {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(c.sign(scope, encoded))) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// sign возвращает HMAC-SHA256 подпись данных выборки scope
+// Нулевой байт разделяет scope и данные, чтобы их границу нельзя было сдвинуть
+func (c *CursorCodec) sign(scope, data string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(scope))
+	mac.Write([]byte{0})
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
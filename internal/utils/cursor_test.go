@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCursorScope - выборка, для которой выдаются курсоры в тестах
+const testCursorScope = "orders?status=paid"
+
+func TestCursorCodecRoundTrip(t *testing.T) {
+	codec := NewCursorCodec("secret")
+
+	cursors := []Cursor{
+		{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), ID: 42},
+		// Наносекунды и часовой пояс не должны теряться, иначе страницы начнут пропускать записи
+		{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.FixedZone("MSK", 3*60*60)), ID: 1},
+		{CreatedAt: time.Time{}, ID: 0},
+	}
+	for _, cursor := range cursors {
+		encoded, err := codec.Encode(cursor, testCursorScope)
+		if err != nil {
+			t.Fatalf("encode %+v: %v", cursor, err)
+		}
+		decoded, err := codec.Decode(encoded, testCursorScope)
+		if err != nil {
+			t.Fatalf("decode %q: %v", encoded, err)
+		}
+		if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+			t.Fatalf("round trip of %+v gave %+v", cursor, decoded)
+		}
+	}
+}
+
+func TestCursorCodecRejectsInvalidCursors(t *testing.T) {
+	codec := NewCursorCodec("secret")
+	valid, err := codec.Encode(Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), ID: 42}, testCursorScope)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	payload, signature, _ := strings.Cut(valid, ".")
+
+	// Подмененная позиция с подписью исходного курсора
+	forgedPayload := base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2024-05-01T12:30:00Z","id":1}`))
+	// Правильно подписанные данные, которые не являются курсором
+	notJSON := base64.RawURLEncoding.EncodeToString([]byte("not json"))
+	notBase64 := "%%%"
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"no signature", payload},
+		{"empty signature", payload + "."},
+		{"forged payload", forgedPayload + "." + signature},
+		{"tampered signature", payload + "." + strings.Repeat("A", len(signature))},
+		{"signed with another secret", func() string {
+			other, err := NewCursorCodec("other-secret").Encode(Cursor{ID: 42}, testCursorScope)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			return other
+		}()},
+		{"issued for another list", func() string {
+			other, err := codec.Encode(Cursor{ID: 42}, "users?min_age=0")
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			return other
+		}()},
+		{"issued for other filters", func() string {
+			other, err := codec.Encode(Cursor{ID: 42}, "orders?status=pending")
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			return other
+		}()},
+		{"signed payload is not JSON", notJSON + "." + codec.sign(testCursorScope, notJSON)},
+		{"signed payload is not base64", notBase64 + "." + codec.sign(testCursorScope, notBase64)},
+		{"extra segment", valid + ".extra"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cursor, err := codec.Decode(tt.value, testCursorScope); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("expected ErrInvalidCursor, got %+v, %v", cursor, err)
+			}
+		})
+	}
+}
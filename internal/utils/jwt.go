@@ -1,40 +1,308 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
+	"go-crud-api/internal/config"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// GenerateToken создает JWT токен для пользователя
-// Включает ID пользователя и время жизни токена (24 часа)
-// Использует секретный ключ из переменных окружения
-func GenerateToken(userID uint) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+// Claims содержит данные, которые передаются в access-токене
+// OrgID - организация пользователя, к данным которой ограничен токен.
+// Scope - области доступа токена через пробел, как в OAuth 2.0.
+// При входе от имени пользователя Actor содержит сотрудника, который на самом деле выполняет запросы
+type Claims struct {
+	UserID    uint         `json:"user_id"`
+	OrgID     uint         `json:"org_id"`
+	Role      string       `json:"role"`
+	SessionID string       `json:"sid"`
+	Scope     string       `json:"scope,omitempty"`
+	Actor     *ActorClaims `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Scopes возвращает области доступа токена
+// Пустой список означает токен без ограничений (выданный до появления областей)
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// ActorClaims описывает сотрудника, действующего от имени пользователя (claim act, RFC 8693)
+type ActorClaims struct {
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// JWK представляет открытый ключ в формате JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // Модуль RSA
+	E   string `json:"e,omitempty"`   // Экспонента RSA
+	Crv string `json:"crv,omitempty"` // Кривая для OKP (Ed25519)
+	X   string `json:"x,omitempty"`   // Открытый ключ Ed25519
+}
+
+// JWKSet представляет набор открытых ключей для /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// jwtKey описывает один ключ проверки (и, для текущего ключа, подписи) токенов
+// Каждый ключ жестко связан со своим алгоритмом
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
 
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+// JWTManager выпускает и проверяет access-токены
+// Токены подписываются текущим ключом, проверяются любым из известных ключей по kid.
+// Это позволяет менять ключ подписи, не отклоняя уже выданные токены
+type JWTManager struct {
+	current  *jwtKey
+	keys     map[string]*jwtKey
+	methods  []string
+	issuer   string
+	audience string
+}
+
+// NewJWTManager создает JWTManager по настройкам JWT
+// Для HS256 используется JWT_SECRET, для RS256 и EdDSA - закрытый ключ из PEM файла
+// и дополнительные открытые ключи для проверки токенов, подписанных до ротации
+func NewJWTManager(cfg config.JWTConfig) (*JWTManager, error) {
+	var current *jwtKey
+	switch cfg.Algorithm {
+	case "HS256":
+		current = &jwtKey{
+			id:        cfg.KeyID,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(cfg.Secret),
+			verifyKey: []byte(cfg.Secret),
+		}
+	case "RS256", "EdDSA":
+		key, err := loadPrivateKey(cfg.KeyID, cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.method.Alg() != cfg.Algorithm {
+			return nil, fmt.Errorf("JWT private key is %s, but JWT_ALGORITHM is %s", key.method.Alg(), cfg.Algorithm)
+		}
+		current = key
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: %s", cfg.Algorithm)
+	}
+
+	m := &JWTManager{
+		current:  current,
+		keys:     map[string]*jwtKey{current.id: current},
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+	}
+
+	for kid, path := range cfg.VerifyKeyFiles {
+		if _, exists := m.keys[kid]; exists {
+			return nil, fmt.Errorf("duplicate JWT key id: %s", kid)
+		}
+		key, err := loadPublicKey(kid, path)
+		if err != nil {
+			return nil, err
+		}
+		m.keys[kid] = key
+	}
+
+	seen := map[string]bool{}
+	for _, key := range m.keys {
+		if !seen[key.method.Alg()] {
+			seen[key.method.Alg()] = true
+			m.methods = append(m.methods, key.method.Alg())
+		}
+	}
+
+	return m, nil
+}
+
+// GenerateToken создает access-токен для пользователя
+// Включает ID, организацию и роль пользователя, ID сессии, области доступа, уникальный идентификатор
+// токена (jti), издателя и получателя. В заголовке указывается kid ключа подписи
+func (m *JWTManager) GenerateToken(userID, orgID uint, role string, sessionID string, scopes []string, ttl time.Duration) (string, *Claims, error) {
+	return m.sign(&Claims{UserID: userID, OrgID: orgID, Role: role, SessionID: sessionID, Scope: strings.Join(scopes, " ")}, ttl)
+}
+
+// GenerateImpersonationToken создает access-токен для работы сотрудника от имени пользователя
+// Токен содержит данные пользователя и claim act с данными сотрудника
+func (m *JWTManager) GenerateImpersonationToken(userID, orgID uint, role string, sessionID string, scopes []string, actor ActorClaims, ttl time.Duration) (string, *Claims, error) {
+	return m.sign(&Claims{UserID: userID, OrgID: orgID, Role: role, SessionID: sessionID, Scope: strings.Join(scopes, " "), Actor: &actor}, ttl)
+}
+
+// sign дополняет claims стандартными полями и подписывает токен текущим ключом
+func (m *JWTManager) sign(claims *Claims, ttl time.Duration) (string, *Claims, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		Issuer:    m.issuer,
+		Audience:  jwt.ClaimStrings{m.audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+
+	token := jwt.NewWithClaims(m.current.method, claims)
+	token.Header["kid"] = m.current.id
+	signed, err := token.SignedString(m.current.signKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
 // ParseToken проверяет и разбирает JWT токен
-// Возвращает ID пользователя из токена
-// Проверяет подпись и срок действия токена
-func ParseToken(tokenString string) (uint, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+// Ключ выбирается по kid, алгоритм токена должен совпадать с алгоритмом этого ключа.
+// Проверяет подпись, срок действия, издателя и получателя
+func (m *JWTManager) ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := m.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.verifyKey, nil
+	},
+		jwt.WithValidMethods(m.methods),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(m.audience),
+		jwt.WithIssuedAt(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.ExpiresAt == nil || claims.ID == "" || claims.SessionID == "" {
+		return nil, errors.New("invalid token claims")
+	}
+
+	return claims, nil
+}
+
+// JWKS возвращает открытые ключи для проверки токенов другими сервисами
+// Ключи HS256 не публикуются
+func (m *JWTManager) JWKS() JWKSet {
+	kids := make([]string, 0, len(m.keys))
+	for kid := range m.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, kid := range kids {
+		key := m.keys[kid]
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return set
+}
 
+// loadPrivateKey загружает закрытый ключ RSA или Ed25519 из PEM файла
+// Поддерживаются форматы PKCS#8 и PKCS#1 (для RSA)
+func loadPrivateKey(kid, path string) (*jwtKey, error) {
+	block, err := readPEM(path)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	var private crypto.PrivateKey
+	if private, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if private, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to parse private key %s: %v", path, err)
+		}
+	}
+
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		return &jwtKey{id: kid, method: jwt.SigningMethodRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &jwtKey{id: kid, method: jwt.SigningMethodEdDSA, signKey: k, verifyKey: k.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type in %s", path)
 	}
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := uint(claims["user_id"].(float64))
-		return userID, nil
+// loadPublicKey загружает открытый ключ RSA или Ed25519 из PEM файла
+func loadPublicKey(kid, path string) (*jwtKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
 	}
 
-	return 0, jwt.ErrSignatureInvalid
+	var public crypto.PublicKey
+	if public, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		if public, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %v", path, err)
+		}
+	}
+
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return &jwtKey{id: kid, method: jwt.SigningMethodRS256, verifyKey: k}, nil
+	case ed25519.PublicKey:
+		return &jwtKey{id: kid, method: jwt.SigningMethodEdDSA, verifyKey: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type in %s", path)
+	}
+}
+
+// readPEM читает первый PEM блок из файла
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block, nil
 }
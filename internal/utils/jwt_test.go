@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-crud-api/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "go-crud-api"
+	testAudience = "go-crud-api"
+)
+
+// writePEM сохраняет DER-данные ключа в PEM файл во временном каталоге теста
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+// testKeys - ключи менеджера RS256 с текущим ключом "current" и ключом "old" после ротации
+type testKeys struct {
+	current    *rsa.PrivateKey
+	currentPEM []byte // Открытый ключ "current" в PEM, как его публикуют вне сервиса
+	old        ed25519.PrivateKey
+}
+
+// newTestJWTManager создает JWTManager RS256 с ключом проверки Ed25519, оставшимся после ротации
+func newTestJWTManager(t *testing.T) (*JWTManager, testKeys) {
+	t.Helper()
+
+	current, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(current)
+	if err != nil {
+		t.Fatalf("marshal RSA key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&current.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal RSA public key: %v", err)
+	}
+
+	oldPublic, old, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate Ed25519 key: %v", err)
+	}
+	oldDER, err := x509.MarshalPKIXPublicKey(oldPublic)
+	if err != nil {
+		t.Fatalf("marshal Ed25519 public key: %v", err)
+	}
+
+	manager, err := NewJWTManager(config.JWTConfig{
+		Algorithm:      "RS256",
+		KeyID:          "current",
+		PrivateKeyFile: writePEM(t, "current.pem", "PRIVATE KEY", privateDER),
+		VerifyKeyFiles: map[string]string{"old": writePEM(t, "old.pem", "PUBLIC KEY", oldDER)},
+		Issuer:         testIssuer,
+		Audience:       testAudience,
+	})
+	if err != nil {
+		t.Fatalf("create JWT manager: %v", err)
+	}
+
+	keys := testKeys{
+		current:    current,
+		currentPEM: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+		old:        old,
+	}
+	return manager, keys
+}
+
+// testClaims возвращает корректные claims, которые тесты портят по одному полю
+func testClaims() *Claims {
+	now := time.Now()
+	return &Claims{
+		UserID:    1,
+		OrgID:     1,
+		Role:      "user",
+		SessionID: "session",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+// signTestToken подписывает claims алгоритмом method и ключом key, kid пустой - без заголовка kid
+func signTestToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims *Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func TestJWTManagerParseToken(t *testing.T) {
+	manager, keys := newTestJWTManager(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr bool
+	}{
+		{"issued by manager", func() string {
+			token, _, err := manager.GenerateToken(1, 1, "user", "session", nil, time.Minute)
+			if err != nil {
+				t.Fatalf("generate token: %v", err)
+			}
+			return token
+		}, false},
+		{"signed with rotated key", func() string {
+			return signTestToken(t, jwt.SigningMethodEdDSA, keys.old, "old", testClaims())
+		}, false},
+
+		{"alg none", func() string {
+			return signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "current", testClaims())
+		}, true},
+		{"alg none without kid", func() string {
+			return signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", testClaims())
+		}, true},
+		// Классическая подмена алгоритма: открытый ключ RS256 используется как секрет HMAC
+		{"HS256 signed with RS public key", func() string {
+			return signTestToken(t, jwt.SigningMethodHS256, keys.currentPEM, "current", testClaims())
+		}, true},
+		{"HS256 signed with RS modulus", func() string {
+			return signTestToken(t, jwt.SigningMethodHS256, keys.current.PublicKey.N.Bytes(), "current", testClaims())
+		}, true},
+		{"RS256 with kid of Ed25519 key", func() string {
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "old", testClaims())
+		}, true},
+		{"EdDSA with kid of RSA key", func() string {
+			return signTestToken(t, jwt.SigningMethodEdDSA, keys.old, "current", testClaims())
+		}, true},
+
+		{"unknown kid", func() string {
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "unknown", testClaims())
+		}, true},
+		{"missing kid", func() string {
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "", testClaims())
+		}, true},
+		{"foreign key with known kid", func() string {
+			return signTestToken(t, jwt.SigningMethodRS256, otherKey, "current", testClaims())
+		}, true},
+
+		{"wrong audience", func() string {
+			claims := testClaims()
+			claims.Audience = jwt.ClaimStrings{"another-service"}
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "current", claims)
+		}, true},
+		{"missing audience", func() string {
+			claims := testClaims()
+			claims.Audience = nil
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "current", claims)
+		}, true},
+		{"wrong issuer", func() string {
+			claims := testClaims()
+			claims.Issuer = "another-issuer"
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "current", claims)
+		}, true},
+		{"expired", func() string {
+			claims := testClaims()
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "current", claims)
+		}, true},
+		{"missing expiry", func() string {
+			claims := testClaims()
+			claims.ExpiresAt = nil
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "current", claims)
+		}, true},
+		{"issued in the future", func() string {
+			claims := testClaims()
+			claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "current", claims)
+		}, true},
+		{"missing session", func() string {
+			claims := testClaims()
+			claims.SessionID = ""
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "current", claims)
+		}, true},
+		{"missing jti", func() string {
+			claims := testClaims()
+			claims.ID = ""
+			return signTestToken(t, jwt.SigningMethodRS256, keys.current, "current", claims)
+		}, true},
+		{"tampered payload", func() string {
+			// Подпись настоящего токена с подмененными claims
+			token := strings.Split(signTestToken(t, jwt.SigningMethodRS256, keys.current, "current", testClaims()), ".")
+			claims := testClaims()
+			claims.Role = "admin"
+			forged := strings.Split(signTestToken(t, jwt.SigningMethodRS256, otherKey, "current", claims), ".")
+			return token[0] + "." + forged[1] + "." + token[2]
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := manager.ParseToken(tt.token())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("token was accepted: %+v", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("valid token was rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestJWTManagerHS256RejectsOtherAlgorithms(t *testing.T) {
+	manager, err := NewJWTManager(config.JWTConfig{
+		Algorithm: "HS256",
+		KeyID:     "default",
+		Secret:    "test-secret",
+		Issuer:    testIssuer,
+		Audience:  testAudience,
+	})
+	if err != nil {
+		t.Fatalf("create JWT manager: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"HS256", signTestToken(t, jwt.SigningMethodHS256, []byte("test-secret"), "default", testClaims()), false},
+		{"HS256 wrong secret", signTestToken(t, jwt.SigningMethodHS256, []byte("other-secret"), "default", testClaims()), true},
+		{"HS512 same secret", signTestToken(t, jwt.SigningMethodHS512, []byte("test-secret"), "default", testClaims()), true},
+		{"alg none", signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "default", testClaims()), true},
+		{"RS256", signTestToken(t, jwt.SigningMethodRS256, rsaKey, "default", testClaims()), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := manager.ParseToken(tt.token)
+			if tt.wantErr && err == nil {
+				t.Fatal("token was accepted")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("valid token was rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestJWTManagerJWKSPublishesOnlyPublicKeys(t *testing.T) {
+	manager, _ := newTestJWTManager(t)
+
+	set := manager.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("expected 2 keys, got %+v", set.Keys)
+	}
+	// Ключи отсортированы по kid
+	if set.Keys[0].Kid != "current" || set.Keys[0].Alg != "RS256" || set.Keys[0].N == "" {
+		t.Fatalf("unexpected RSA key: %+v", set.Keys[0])
+	}
+	if set.Keys[1].Kid != "old" || set.Keys[1].Alg != "EdDSA" || set.Keys[1].Crv != "Ed25519" {
+		t.Fatalf("unexpected Ed25519 key: %+v", set.Keys[1])
+	}
+
+	hsManager, err := NewJWTManager(config.JWTConfig{Algorithm: "HS256", KeyID: "default", Secret: "test-secret"})
+	if err != nil {
+		t.Fatalf("create JWT manager: %v", err)
+	}
+	if keys := hsManager.JWKS().Keys; len(keys) != 0 {
+		t.Fatalf("HS256 secret was published: %+v", keys)
+	}
+}
@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"context"
 	"log"
 	"os"
+
+	"go-crud-api/internal/tracing"
 )
 
 var (
@@ -23,16 +26,30 @@ func init() {
 }
 
 // LogOperation логирует операцию с пользователем
-func LogOperation(operation string, userID uint, details string) {
-	InfoLogger.Printf("[%s] UserID: %d, Details: %s", operation, userID, details)
+func LogOperation(ctx context.Context, operation string, userID uint, details string) {
+	InfoLogger.Printf("[%s] UserID: %d, Details: %s%s", operation, userID, details, traceSuffix(ctx))
 }
 
 // LogError логирует ошибку
-func LogError(operation string, err error) {
-	ErrorLogger.Printf("[%s] Error: %v", operation, err)
+func LogError(ctx context.Context, operation string, err error) {
+	ErrorLogger.Printf("[%s] Error: %v%s", operation, err, traceSuffix(ctx))
 }
 
 // LogOrderOperation логирует операцию с заказом
-func LogOrderOperation(operation string, orderID uint, userID uint, details string) {
-	InfoLogger.Printf("[%s] OrderID: %d, UserID: %d, Details: %s", operation, orderID, userID, details)
+func LogOrderOperation(ctx context.Context, operation string, orderID uint, userID uint, details string) {
+	InfoLogger.Printf("[%s] OrderID: %d, UserID: %d, Details: %s%s", operation, orderID, userID, details, traceSuffix(ctx))
+}
+
+// LogActorOperation логирует операцию, выполненную сотрудником от имени пользователя
+func LogActorOperation(ctx context.Context, operation string, userID uint, actorID uint, details string) {
+	InfoLogger.Printf("[%s] UserID: %d, ActorID: %d, Details: %s%s", operation, userID, actorID, details, traceSuffix(ctx))
+}
+
+// traceSuffix возвращает ID трассировки запроса для строки лога
+// По нему запись в логе находится в трассировке и наоборот
+func traceSuffix(ctx context.Context) string {
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		return ", TraceID: " + traceID
+	}
+	return ""
 }
@@ -30,13 +30,8 @@ func RunMigrations() error {
 		return err
 	}
 
-	// Настройка миграций
-	migrations := &migrate.FileMigrationSource{
-		Dir: "migrations",
-	}
-
 	// Применение миграций
-	n, err := migrate.Exec(db, "postgres", migrations, migrate.Up)
+	n, err := migrate.Exec(db, "postgres", migrationSource(), migrate.Up)
 	if err != nil {
 		return err
 	}
@@ -44,3 +39,19 @@ func RunMigrations() error {
 	log.Printf("Applied %d migrations!\n", n)
 	return nil
 }
+
+// PendingMigrations возвращает число миграций из каталога migrations, еще не примененных к БД
+func PendingMigrations(db *sql.DB) (int, error) {
+	planned, _, err := migrate.PlanMigration(db, "postgres", migrationSource(), migrate.Up, 0)
+	if err != nil {
+		return 0, err
+	}
+	return len(planned), nil
+}
+
+// migrationSource возвращает источник миграций приложения
+func migrationSource() migrate.MigrationSource {
+	return &migrate.FileMigrationSource{
+		Dir: "migrations",
+	}
+}
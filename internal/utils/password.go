@@ -1,24 +1,130 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-crud-api/internal/config"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword создает хеш пароля с использованием bcrypt
-// Использует стандартную стоимость хеширования (10 раундов)
-// Возвращает хеш в виде строки
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errInvalidArgon2Hash = errors.New("invalid argon2id hash")
+
+// PasswordHasher хеширует и проверяет пароли алгоритмом из конфигурации
+// Проверка понимает хеши обоих алгоритмов, поэтому смена алгоритма не ломает вход
+type PasswordHasher struct {
+	cfg config.PasswordConfig
+}
+
+func NewPasswordHasher(cfg config.PasswordConfig) *PasswordHasher {
+	return &PasswordHasher{cfg: cfg}
+}
+
+// Hash создает хеш пароля текущим алгоритмом
+// argon2id сохраняется в PHC-формате: $argon2id$v=19$m=...,t=...,p=...$salt$hash
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == "argon2id" {
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.cfg.Argon2Time, h.cfg.Argon2Memory, h.cfg.Argon2Threads, argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, h.cfg.Argon2Memory, h.cfg.Argon2Time, h.cfg.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
 	if err != nil {
 		return "", err
 	}
 	return string(hashedPassword), nil
 }
 
-// CheckPasswordHash сравнивает пароль с его хешем
-// Возвращает true если пароль совпадает с хешем
-// Использует безопасное сравнение для предотвращения timing-атак
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// Verify сравнивает пароль с хешем любого поддерживаемого алгоритма
+// Сравнение выполняется за постоянное время
+func (h *PasswordHasher) Verify(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := parseArgon2Hash(hash)
+		if err != nil {
+			return false
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(actual, key) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NeedsRehash сообщает, что хеш создан другим алгоритмом или с другими параметрами
+// Такой хеш пересчитывается при следующем успешном входе
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		if h.cfg.Algorithm != "argon2id" {
+			return true
+		}
+		params, _, key, err := parseArgon2Hash(hash)
+		if err != nil {
+			return true
+		}
+		return params.memory != h.cfg.Argon2Memory || params.time != h.cfg.Argon2Time ||
+			params.threads != h.cfg.Argon2Threads || len(key) != argon2KeyLength
+	}
+
+	if h.cfg.Algorithm != "bcrypt" {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cfg.BcryptCost
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// parseArgon2Hash разбирает хеш argon2id в PHC-формате
+func parseArgon2Hash(hash string) (*argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, errInvalidArgon2Hash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, errInvalidArgon2Hash
+	}
+
+	params := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, nil, nil, errInvalidArgon2Hash
+	}
+	// argon2.IDKey паникует при нулевом числе проходов или потоков
+	if params.memory == 0 || params.time == 0 || params.threads == 0 {
+		return nil, nil, nil, errInvalidArgon2Hash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, errInvalidArgon2Hash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, errInvalidArgon2Hash
+	}
+
+	return params, salt, key, nil
 }
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"testing"
+
+	"go-crud-api/internal/config"
+)
+
+// newTestArgon2Hasher создает хешер argon2id с минимальными параметрами
+func newTestArgon2Hasher() *PasswordHasher {
+	return NewPasswordHasher(config.PasswordConfig{Algorithm: "argon2id", Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1})
+}
+
+func TestPasswordHasherArgon2RoundTrip(t *testing.T) {
+	h := newTestArgon2Hasher()
+
+	hash, err := h.Hash("password")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !h.Verify("password", hash) {
+		t.Fatal("correct password was rejected")
+	}
+	if h.Verify("wrong-password", hash) {
+		t.Fatal("wrong password was accepted")
+	}
+	if h.NeedsRehash(hash) {
+		t.Fatal("hash with current parameters needs rehash")
+	}
+}
+
+func TestPasswordHasherRejectsInvalidArgon2Params(t *testing.T) {
+	h := newTestArgon2Hasher()
+
+	// Соль и ключ корректны, ошибка только в параметрах
+	const tail = "$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	hashes := map[string]string{
+		"zero threads": "$argon2id$v=19$m=64,t=1,p=0" + tail,
+		"zero time":    "$argon2id$v=19$m=64,t=0,p=1" + tail,
+		"zero memory":  "$argon2id$v=19$m=0,t=1,p=1" + tail,
+		"bad params":   "$argon2id$v=19$m=64,t=1" + tail,
+		"bad version":  "$argon2id$v=18$m=64,t=1,p=1" + tail,
+	}
+	for name, hash := range hashes {
+		t.Run(name, func(t *testing.T) {
+			// Хеш из БД с такими параметрами не должен ронять вход паникой
+			if h.Verify("password", hash) {
+				t.Fatal("password was accepted")
+			}
+			if !h.NeedsRehash(hash) {
+				t.Fatal("invalid hash does not need rehash")
+			}
+		})
+	}
+}
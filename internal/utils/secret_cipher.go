@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EncryptedSecretPrefix отмечает значения, зашифрованные SecretCipher, и версию формата
+// Base32-секреты TOTP, сохраненные до шифрования, не содержат двоеточия
+const EncryptedSecretPrefix = "v1:"
+
+// ErrInvalidCiphertext возвращается для поврежденного значения или значения, зашифрованного другим ключом
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// SecretCipher шифрует секреты для хранения в БД (AES-256-GCM)
+// Дополнительные данные (например, ID пользователя) привязывают шифротекст к записи:
+// значение, скопированное в другую запись, не расшифруется
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+// NewSecretCipher создает SecretCipher с ключом длиной 32 байта
+func NewSecretCipher(key []byte) (*SecretCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretCipher{aead: aead}, nil
+}
+
+// Encrypt шифрует значение и возвращает строку вида v1:<base64(nonce|шифротекст)>
+func (c *SecretCipher) Encrypt(plaintext, additionalData string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData))
+	return EncryptedSecretPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt расшифровывает значение, созданное Encrypt с теми же дополнительными данными
+func (c *SecretCipher) Decrypt(value, additionalData string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, EncryptedSecretPrefix)
+	if !ok {
+		return "", ErrInvalidCiphertext
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted проверяет, что значение сохранено в формате Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedSecretPrefix)
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestSecretCipher создает SecretCipher с ключом из повторяющегося байта
+func newTestSecretCipher(t *testing.T, fill byte) *SecretCipher {
+	t.Helper()
+
+	c, err := NewSecretCipher(bytes.Repeat([]byte{fill}, 32))
+	if err != nil {
+		t.Fatalf("create cipher: %v", err)
+	}
+	return c
+}
+
+func TestSecretCipherRoundTrip(t *testing.T) {
+	c := newTestSecretCipher(t, 1)
+
+	encrypted, err := c.Encrypt(rfc6238Secret, "totp:1")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, rfc6238Secret) {
+		t.Fatalf("value is not encrypted: %s", encrypted)
+	}
+
+	decrypted, err := c.Decrypt(encrypted, "totp:1")
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if decrypted != rfc6238Secret {
+		t.Fatalf("got %q, want %q", decrypted, rfc6238Secret)
+	}
+
+	again, err := c.Encrypt(rfc6238Secret, "totp:1")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if again == encrypted {
+		t.Fatal("nonce is reused: equal secrets give equal ciphertexts")
+	}
+}
+
+func TestSecretCipherRejectsInvalidValues(t *testing.T) {
+	c := newTestSecretCipher(t, 1)
+	encrypted, err := c.Encrypt(rfc6238Secret, "totp:1")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	tampered := []byte(encrypted)
+	last := len(tampered) - 1
+	if tampered[last] == 'A' {
+		tampered[last] = 'B'
+	} else {
+		tampered[last] = 'A'
+	}
+
+	tests := []struct {
+		name   string
+		cipher *SecretCipher
+		value  string
+		aad    string
+	}{
+		{"other record", c, encrypted, "totp:2"},
+		{"other key", newTestSecretCipher(t, 2), encrypted, "totp:1"},
+		{"tampered", c, string(tampered), "totp:1"},
+		{"truncated", c, encrypted[:len(encrypted)-4], "totp:1"},
+		{"prefix only", c, EncryptedSecretPrefix, "totp:1"},
+		{"not base64", c, EncryptedSecretPrefix + "!!!", "totp:1"},
+		{"plaintext", c, rfc6238Secret, "totp:1"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.cipher.Decrypt(tt.value, tt.aad); !errors.Is(err, ErrInvalidCiphertext) {
+			t.Errorf("%s: expected ErrInvalidCiphertext, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestNewSecretCipherKeyLength(t *testing.T) {
+	for _, size := range []int{0, 16, 24, 31, 33} {
+		if _, err := NewSecretCipher(make([]byte, size)); err == nil {
+			t.Errorf("key of %d bytes was accepted", size)
+		}
+	}
+}
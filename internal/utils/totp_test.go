@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret - ключ SHA1 из тестовых векторов RFC 6238 ("12345678901234567890") в base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfc6238Vectors - тестовые векторы RFC 6238 (приложение B) для SHA1
+// В RFC коды из 8 цифр, при 6 цифрах код - последние 6 цифр того же значения
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},          // 94287082
+	{1111111109, "081804"},  // 07081804
+	{1111111111, "050471"},  // 14050471
+	{1234567890, "005924"},  // 89005924
+	{2000000000, "279037"},  // 69279037
+	{20000000000, "353130"}, // 65353130
+}
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	for _, tt := range rfc6238Vectors {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("T=%d: got %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		at := time.Unix(tt.unix, 0)
+		step := tt.unix / totpPeriod
+
+		tests := []struct {
+			name   string
+			now    time.Time
+			wantOK bool
+		}{
+			{"same step", at, true},
+			{"previous step", at.Add(totpPeriod * time.Second), true},
+			{"next step", at.Add(-totpPeriod * time.Second), true},
+			{"two steps late", at.Add(2 * totpPeriod * time.Second), false},
+			{"two steps early", at.Add(-2 * totpPeriod * time.Second), false},
+		}
+		for _, check := range tests {
+			if check.now.Unix() < 0 {
+				continue // Время до 1970 года не встречается
+			}
+			got, ok := ValidateTOTP(rfc6238Secret, tt.code, check.now)
+			if ok != check.wantOK {
+				t.Errorf("T=%d, %s: ok=%v, want %v", tt.unix, check.name, ok, check.wantOK)
+				continue
+			}
+			// Возвращается шаг самого кода, а не текущий шаг: по нему запрещается повтор
+			if ok && got != step {
+				t.Errorf("T=%d, %s: step %d, want %d", tt.unix, check.name, got, step)
+			}
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"wrong code", rfc6238Secret, "287083"},
+		{"eight digits", rfc6238Secret, "94287082"},
+		{"five digits", rfc6238Secret, "87082"},
+		{"empty code", rfc6238Secret, ""},
+		{"invalid secret", "not base32!", "287082"},
+		{"empty secret", "", "287082"},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, at); ok {
+			t.Errorf("%s: code was accepted", tt.name)
+		}
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	first, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	second, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	if first == second {
+		t.Fatal("secrets are not random")
+	}
+
+	key, err := totpEncoding.DecodeString(first)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("expected 160-bit base32 secret, got %q (%v)", first, err)
+	}
+}
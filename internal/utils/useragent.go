@@ -0,0 +1,60 @@
+package utils
+
+import "strings"
+
+// uaPattern связывает подстроку User-Agent с понятным названием
+type uaPattern struct {
+	token string
+	name  string
+}
+
+// Порядок важен: Edge и Opera содержат "Chrome", Chrome содержит "Safari"
+var (
+	uaBrowsers = []uaPattern{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"PostmanRuntime/", "Postman"},
+		{"curl/", "curl"},
+		{"Go-http-client/", "Go"},
+		{"python-requests/", "Python"},
+	}
+	uaSystems = []uaPattern{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DescribeUserAgent возвращает краткое описание устройства по заголовку User-Agent
+// Например "Chrome on Windows". Для неизвестных клиентов возвращает "Unknown device"
+func DescribeUserAgent(userAgent string) string {
+	browser := matchUserAgent(userAgent, uaBrowsers)
+	system := matchUserAgent(userAgent, uaSystems)
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
+
+// matchUserAgent возвращает название первого шаблона, найденного в User-Agent
+func matchUserAgent(userAgent string, patterns []uaPattern) string {
+	for _, p := range patterns {
+		if strings.Contains(userAgent, p.token) {
+			return p.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,18 @@
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE orders ADD COLUMN product VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN quantity INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN price DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+-- Возвращаем в заказ данные первой позиции
+UPDATE orders o SET product = i.product, quantity = i.quantity, price = i.unit_price
+FROM (
+    SELECT DISTINCT ON (order_id) order_id, product, quantity, unit_price
+    FROM order_items ORDER BY order_id, id
+) i
+WHERE i.order_id = o.id;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS total;
+ALTER TABLE orders DROP COLUMN IF EXISTS subtotal;
+DROP TABLE IF EXISTS order_items;
@@ -0,0 +1,28 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE order_items (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id),
+    product VARCHAR(255) NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10,2) NOT NULL,
+    line_total DECIMAL(12,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX order_items_order_id_idx ON order_items (order_id);
+
+ALTER TABLE orders ADD COLUMN subtotal DECIMAL(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN total DECIMAL(12,2) NOT NULL DEFAULT 0;
+
+-- Каждый существующий заказ превращается в заказ из одной позиции
+INSERT INTO order_items (order_id, product, quantity, unit_price, line_total, created_at, updated_at)
+SELECT id, product, quantity, price, quantity * price, created_at, updated_at FROM orders;
+
+UPDATE orders SET subtotal = quantity * price, total = quantity * price;
+
+ALTER TABLE orders DROP COLUMN product;
+ALTER TABLE orders DROP COLUMN quantity;
+ALTER TABLE orders DROP COLUMN price;
@@ -0,0 +1,5 @@
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_id;
+DROP TABLE IF EXISTS products;
@@ -0,0 +1,6 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Остаток товара на складе. Ограничение не дает уйти в минус даже при ошибке в коде
+ALTER TABLE products ADD COLUMN stock INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD CONSTRAINT products_stock_check CHECK (stock >= 0);
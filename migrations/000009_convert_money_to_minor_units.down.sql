@@ -0,0 +1,12 @@
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+ALTER TABLE orders ALTER COLUMN total TYPE DECIMAL(12,2) USING total / 100.0;
+ALTER TABLE orders ALTER COLUMN subtotal TYPE DECIMAL(12,2) USING subtotal / 100.0;
+
+ALTER TABLE order_items ALTER COLUMN line_total TYPE DECIMAL(12,2) USING line_total / 100.0;
+ALTER TABLE order_items ALTER COLUMN unit_price TYPE DECIMAL(10,2) USING unit_price / 100.0;
+
+ALTER TABLE products DROP COLUMN IF EXISTS currency;
+ALTER TABLE products ALTER COLUMN price TYPE DECIMAL(10,2) USING price / 100.0;
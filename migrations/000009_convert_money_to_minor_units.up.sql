@@ -0,0 +1,13 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Суммы хранятся целым числом минимальных единиц валюты (копеек, центов)
+ALTER TABLE products ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100)::BIGINT;
+ALTER TABLE products ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+ALTER TABLE order_items ALTER COLUMN unit_price TYPE BIGINT USING ROUND(unit_price * 100)::BIGINT;
+ALTER TABLE order_items ALTER COLUMN line_total TYPE BIGINT USING ROUND(line_total * 100)::BIGINT;
+
+ALTER TABLE orders ALTER COLUMN subtotal TYPE BIGINT USING ROUND(subtotal * 100)::BIGINT;
+ALTER TABLE orders ALTER COLUMN total TYPE BIGINT USING ROUND(total * 100)::BIGINT;
+ALTER TABLE orders ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB';
@@ -0,0 +1,6 @@
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP INDEX IF EXISTS orders_user_id_created_at_id_idx;
+DROP INDEX IF EXISTS orders_created_at_id_idx;
+DROP INDEX IF EXISTS users_created_at_id_idx;
@@ -0,0 +1,7 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Индексы для keyset-пагинации по (created_at, id)
+CREATE INDEX users_created_at_id_idx ON users (created_at, id);
+CREATE INDEX orders_created_at_id_idx ON orders (created_at, id);
+CREATE INDEX orders_user_id_created_at_id_idx ON orders (user_id, created_at, id);
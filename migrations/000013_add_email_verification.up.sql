@@ -0,0 +1,21 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Пользователи, зарегистрированные до появления подтверждения, считаются подтвержденными
+UPDATE users SET email_verified_at = created_at;
+
+-- Одноразовые токены подтверждения email. Токен действует только для адреса, на который отправлен
+CREATE TABLE email_verification_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX email_verification_tokens_token_hash_key ON email_verification_tokens (token_hash);
+CREATE INDEX email_verification_tokens_user_id_idx ON email_verification_tokens (user_id);
@@ -0,0 +1,5 @@
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS oidc_auth_requests;
+DROP TABLE IF EXISTS external_identities;
@@ -0,0 +1,29 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Связь пользователей с учетными записями у внешних провайдеров OIDC
+CREATE TABLE external_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    provider VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    last_login_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX external_identities_provider_subject_key ON external_identities (provider, subject);
+CREATE INDEX external_identities_user_id_idx ON external_identities (user_id);
+
+-- Начатые входы через OIDC: state, PKCE verifier и nonce до возврата от провайдера
+CREATE TABLE oidc_auth_requests (
+    id SERIAL PRIMARY KEY,
+    state_hash VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX oidc_auth_requests_state_hash_key ON oidc_auth_requests (state_hash);
@@ -0,0 +1,5 @@
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS impersonation_events;
+DROP TABLE IF EXISTS impersonation_sessions;
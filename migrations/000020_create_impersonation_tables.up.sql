@@ -0,0 +1,30 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Журнал входов сотрудников от имени пользователей
+CREATE TABLE impersonation_sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    actor_id INTEGER NOT NULL REFERENCES users(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    reason TEXT NOT NULL,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX impersonation_sessions_actor_id_idx ON impersonation_sessions (actor_id, created_at);
+CREATE INDEX impersonation_sessions_user_id_idx ON impersonation_sessions (user_id, created_at);
+
+-- Запросы, выполненные от имени пользователя
+CREATE TABLE impersonation_events (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL REFERENCES impersonation_sessions(id) ON DELETE CASCADE,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX impersonation_events_session_id_idx ON impersonation_events (session_id, created_at);
@@ -0,0 +1,5 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Области доступа токенов сессии, NULL - все области (обычный вход)
+ALTER TABLE sessions ADD COLUMN scopes TEXT[];
@@ -0,0 +1,11 @@
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+UPDATE users SET role = 'admin' WHERE role = 'superadmin';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'support', 'admin'));
+
+ALTER TABLE impersonation_sessions DROP COLUMN IF EXISTS org_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS org_id;
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,35 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Организация по умолчанию, к ней относятся все существующие данные
+INSERT INTO organizations (id, name, slug) VALUES (1, 'Default', 'default');
+SELECT setval('organizations_id_seq', (SELECT MAX(id) FROM organizations));
+
+ALTER TABLE users ADD COLUMN org_id INTEGER REFERENCES organizations(id);
+UPDATE users SET org_id = 1;
+ALTER TABLE users ALTER COLUMN org_id SET NOT NULL;
+CREATE INDEX users_org_id_idx ON users (org_id);
+
+ALTER TABLE orders ADD COLUMN org_id INTEGER REFERENCES organizations(id);
+UPDATE orders SET org_id = users.org_id FROM users WHERE users.id = orders.user_id;
+UPDATE orders SET org_id = 1 WHERE org_id IS NULL;
+ALTER TABLE orders ALTER COLUMN org_id SET NOT NULL;
+CREATE INDEX orders_org_id_idx ON orders (org_id);
+
+ALTER TABLE impersonation_sessions ADD COLUMN org_id INTEGER REFERENCES organizations(id);
+UPDATE impersonation_sessions SET org_id = users.org_id FROM users WHERE users.id = impersonation_sessions.user_id;
+UPDATE impersonation_sessions SET org_id = 1 WHERE org_id IS NULL;
+ALTER TABLE impersonation_sessions ALTER COLUMN org_id SET NOT NULL;
+CREATE INDEX impersonation_sessions_org_id_idx ON impersonation_sessions (org_id, created_at);
+
+-- Роль администратора платформы
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'support', 'admin', 'superadmin'));
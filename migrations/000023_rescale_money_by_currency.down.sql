@@ -0,0 +1,28 @@
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+UPDATE products SET price = price * 100 WHERE currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF');
+UPDATE products SET price = ROUND(price / 10.0)::BIGINT WHERE currency IN ('BHD','IQD','JOD','KWD','LYD','OMR','TND');
+UPDATE products SET price = ROUND(price / 100.0)::BIGINT WHERE currency IN ('CLF','UYW');
+
+UPDATE order_items SET unit_price = unit_price * 100
+WHERE order_id IN (SELECT id FROM orders WHERE currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF'));
+UPDATE order_items SET unit_price = ROUND(unit_price / 10.0)::BIGINT
+WHERE order_id IN (SELECT id FROM orders WHERE currency IN ('BHD','IQD','JOD','KWD','LYD','OMR','TND'));
+UPDATE order_items SET unit_price = ROUND(unit_price / 100.0)::BIGINT
+WHERE order_id IN (SELECT id FROM orders WHERE currency IN ('CLF','UYW'));
+
+-- Суммы позиций и заказов пересчитываются из цен, как и при применении миграции
+UPDATE order_items SET line_total = unit_price * quantity
+WHERE order_id IN (SELECT id FROM orders WHERE currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF', 'BHD','IQD','JOD','KWD','LYD','OMR','TND', 'CLF','UYW'));
+
+UPDATE orders SET subtotal = items.sum, total = items.sum
+FROM (SELECT order_id, SUM(line_total)::BIGINT AS sum FROM order_items GROUP BY order_id) AS items
+WHERE items.order_id = orders.id AND orders.currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF', 'BHD','IQD','JOD','KWD','LYD','OMR','TND', 'CLF','UYW');
+
+UPDATE orders SET subtotal = subtotal * 100, total = total * 100
+WHERE currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF') AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id);
+UPDATE orders SET subtotal = ROUND(subtotal / 10.0)::BIGINT, total = ROUND(total / 10.0)::BIGINT
+WHERE currency IN ('BHD','IQD','JOD','KWD','LYD','OMR','TND') AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id);
+UPDATE orders SET subtotal = ROUND(subtotal / 100.0)::BIGINT, total = ROUND(total / 100.0)::BIGINT
+WHERE currency IN ('CLF','UYW') AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id);
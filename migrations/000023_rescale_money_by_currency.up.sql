@@ -0,0 +1,36 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- До этой миграции все суммы хранились с двумя знаками после запятой независимо от валюты.
+-- Переводим их в минимальные единицы валюты по ISO 4217:
+-- валюты без дробной части (JPY, KRW, ...) делим на 100, с тремя знаками (KWD, BHD, ...) умножаем на 10,
+-- с четырьмя (CLF, UYW) - на 100
+
+UPDATE products SET price = ROUND(price / 100.0)::BIGINT WHERE currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF');
+UPDATE products SET price = price * 10 WHERE currency IN ('BHD','IQD','JOD','KWD','LYD','OMR','TND');
+UPDATE products SET price = price * 100 WHERE currency IN ('CLF','UYW');
+
+-- Пересчитываем только цену позиции: округленные по отдельности суммы разошлись бы с ценой и количеством
+UPDATE order_items SET unit_price = ROUND(unit_price / 100.0)::BIGINT
+WHERE order_id IN (SELECT id FROM orders WHERE currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF'));
+UPDATE order_items SET unit_price = unit_price * 10
+WHERE order_id IN (SELECT id FROM orders WHERE currency IN ('BHD','IQD','JOD','KWD','LYD','OMR','TND'));
+UPDATE order_items SET unit_price = unit_price * 100
+WHERE order_id IN (SELECT id FROM orders WHERE currency IN ('CLF','UYW'));
+
+-- Суммы позиций и заказов считаются из новых цен так же, как на сервере:
+-- line_total = unit_price * quantity, subtotal и total - сумма позиций заказа
+UPDATE order_items SET line_total = unit_price * quantity
+WHERE order_id IN (SELECT id FROM orders WHERE currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF', 'BHD','IQD','JOD','KWD','LYD','OMR','TND', 'CLF','UYW'));
+
+UPDATE orders SET subtotal = items.sum, total = items.sum
+FROM (SELECT order_id, SUM(line_total)::BIGINT AS sum FROM order_items GROUP BY order_id) AS items
+WHERE items.order_id = orders.id AND orders.currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF', 'BHD','IQD','JOD','KWD','LYD','OMR','TND', 'CLF','UYW');
+
+-- Заказы без позиций пересчитывать не из чего, их суммы переводим напрямую
+UPDATE orders SET subtotal = ROUND(subtotal / 100.0)::BIGINT, total = ROUND(total / 100.0)::BIGINT
+WHERE currency IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG','RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF') AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id);
+UPDATE orders SET subtotal = subtotal * 10, total = total * 10
+WHERE currency IN ('BHD','IQD','JOD','KWD','LYD','OMR','TND') AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id);
+UPDATE orders SET subtotal = subtotal * 100, total = total * 100
+WHERE currency IN ('CLF','UYW') AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id);
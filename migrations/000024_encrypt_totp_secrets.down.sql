@@ -0,0 +1,8 @@
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+-- Предыдущая версия не умеет расшифровывать секреты: у таких пользователей 2FA выключается
+-- и подключается заново
+DELETE FROM recovery_codes WHERE user_id IN (SELECT id FROM users WHERE totp_secret LIKE 'v1:%');
+UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE totp_secret LIKE 'v1:%';
+ALTER TABLE users ALTER COLUMN totp_secret TYPE VARCHAR(64);
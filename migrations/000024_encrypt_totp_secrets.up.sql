@@ -0,0 +1,6 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Секреты TOTP хранятся зашифрованными (AES-256-GCM, ключ TOTP_ENCRYPTION_KEY) и не помещаются в 64 символа.
+-- Сохраненные ранее секреты шифруются приложением при запуске
+ALTER TABLE users ALTER COLUMN totp_secret TYPE VARCHAR(255);